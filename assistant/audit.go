@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 監査ログの末尾の記録を探すために読み込む最大バイト数
+const auditTailReadSize = 64 * 1024
+
+// 監査ログの記録の状態
+const (
+	auditStatusPending   = "pending"   // APIに送信する直前の記録
+	auditStatusCompleted = "completed" // 応答を受け取った後の記録
+	auditStatusFailed    = "error"     // エラーで終了した後の記録
+)
+
+// 監査ログに追記する1回のリクエストの記録
+// 送信前にpendingの記録を、終了後に同じRequestIDで結果の記録を追記する
+// 入力テキストそのものは記録せず、SHA-256のハッシュのみを記録する
+// PrevHashには直前の行のSHA-256を記録し、行の改ざんや削除を検出できるようにする
+type auditRecord struct {
+	Timestamp            time.Time `json:"timestamp"`
+	RequestID            string    `json:"requestId"`
+	Status               string    `json:"status"`
+	User                 string    `json:"user"`
+	Model                string    `json:"model"`
+	Task                 string    `json:"task"`
+	APIMethod            string    `json:"apiMethod"`
+	InputSHA256          string    `json:"inputSha256"`
+	PromptTokenCount     int32     `json:"promptTokenCount"`
+	CandidatesTokenCount int32     `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int32     `json:"thoughtsTokenCount"`
+	TotalTokenCount      int32     `json:"totalTokenCount"`
+	Error                string    `json:"error,omitempty"`
+	PrevHash             string    `json:"prevHash"`
+}
+
+// 監査ログの書き込み先と、記録に共通する値
+type auditLogger struct {
+	path      string
+	apiMethod string
+	user      string
+	mu        sync.Mutex // -jsonl の並行処理で記録が混ざらないよう、追記を1つずつ行う (別のプロセスとはファイルのロックで排他する)
+}
+
+// 監査ログへの記録に失敗したことを表すエラー
+// 記録のないリクエストを送らないよう、このエラーの場合は再試行や後続のリクエストを行わずに終了する
+// 送信前の記録に失敗した場合、そのリクエストはAPIに送信しない
+type auditLogError struct {
+	Err error
+}
+
+func (e *auditLogError) Error() string {
+	return msgf("err.auditLog", e.Err)
+}
+
+func (e *auditLogError) Unwrap() error {
+	return e.Err
+}
+
+// 監査ログへの記録に失敗したことによるエラーかを判定する
+func isAuditLogError(err error) bool {
+	var auditErr *auditLogError
+	return errors.As(err, &auditErr)
+}
+
+// 監査ログ (nilの場合は記録しない)
+// 監査ログが有効な場合、書き込みに失敗したらリクエストを続けずにエラーで終了する
+var auditLog *auditLogger
+
+// 監査ログのパスを決定する (フラグを優先し、次に設定ファイルの値を使う。どちらもなければ空文字列)
+func resolveAuditLogPath(flagPath string, settings *Settings) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	if settings != nil {
+		return settings.AuditLogPath
+	}
+	return ""
+}
+
+// pathに書き込む監査ログを作成する
+// ユーザー名は環境変数 USER (WindowsではUSERNAME) から取得する
+func newAuditLogger(path string, apiMethod string) *auditLogger {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	return &auditLogger{path: path, apiMethod: apiMethod, user: user}
+}
+
+// APIに送信する前に、送信するリクエストのpendingの記録を監査ログに追記する (監査ログが無効な場合は何もしない)
+// 記録に失敗した場合はエラーを返し、呼び出し側はAPIに送信しない
+// 返したリクエストIDは終了後の記録 (record) に渡す
+func (a *auditLogger) begin(llmReqConfig LlmRequestConfig) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+	requestID, err := newAuditRequestID()
+	if err != nil {
+		return "", &auditLogError{Err: err}
+	}
+	if err := a.append(a.newRecord(requestID, auditStatusPending, llmReqConfig)); err != nil {
+		return "", &auditLogError{Err: err}
+	}
+	return requestID, nil
+}
+
+// 1回のリクエストの結果を監査ログに追記する (監査ログが無効な場合は何もしない)
+func (a *auditLogger) record(requestID string, llmReqConfig LlmRequestConfig, metadata LLMMetadata, requestErr error) error {
+	if a == nil {
+		return nil
+	}
+	record := a.newRecord(requestID, auditStatusCompleted, llmReqConfig)
+	record.PromptTokenCount = metadata.PromptTokenCount
+	record.CandidatesTokenCount = metadata.CandidatesTokenCount
+	record.ThoughtsTokenCount = metadata.ThoughtsTokenCount
+	record.TotalTokenCount = metadata.TotalTokenCount
+	if requestErr != nil {
+		record.Status = auditStatusFailed
+		record.Error = requestErr.Error()
+	}
+	if err := a.append(record); err != nil {
+		return &auditLogError{Err: err}
+	}
+	return nil
+}
+
+// リクエストに共通する項目の記録を作成する
+// 入力のハッシュは送信する入力テキスト (タスクのプレフィックスを含む) から計算する
+func (a *auditLogger) newRecord(requestID string, status string, llmReqConfig LlmRequestConfig) auditRecord {
+	inputHash := sha256.Sum256([]byte(llmReqConfig.InputText))
+	return auditRecord{
+		Timestamp:   time.Now(),
+		RequestID:   requestID,
+		Status:      status,
+		User:        a.user,
+		Model:       llmReqConfig.Model,
+		Task:        llmReqConfig.TaskName,
+		APIMethod:   a.apiMethod,
+		InputSHA256: hex.EncodeToString(inputHash[:]),
+	}
+}
+
+// pendingの記録と結果の記録を対応付けるランダムなIDを作成する
+func newAuditRequestID() (string, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", errorf("err.createRequestID", err)
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// 直前の行のハッシュを添えて記録を監査ログに追記する
+// 同時に実行された別のプロセスとハッシュの連鎖が分岐しないよう、直前の行の読み込みから追記までファイルをロックする
+func (a *auditLogger) append(record auditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0700); err != nil {
+		return errorf("err.createDir", err)
+	}
+	f, err := os.OpenFile(a.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return errorf("err.lockFile", err)
+	}
+	defer unlockFile(f)
+
+	lastLine, err := readLastLine(f)
+	if err != nil {
+		return errorf("err.readPreviousRecord", err)
+	}
+	if lastLine != nil {
+		prevHash := sha256.Sum256(lastLine)
+		record.PrevHash = hex.EncodeToString(prevHash[:])
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errorf("err.serializeRecord", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// ファイルの最後の空でない行を返す (空のファイルの場合はnil)
+func readLastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	offset := max(size-auditTailReadSize, 0)
+	buf := make([]byte, size-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = bytes.TrimRight(buf, "\r\n")
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return buf[bytes.LastIndexByte(buf, '\n')+1:], nil
+}
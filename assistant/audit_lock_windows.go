@@ -0,0 +1,27 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// ロックする範囲 (Windowsのロックは強制ロックのため、他のプロセスによる読み込みを妨げないようファイルの末尾より先の1バイトをロックする)
+const (
+	lockOffsetHigh = 0xFFFFFFFF
+	lockLength     = 1
+)
+
+// 監査ログのファイルを排他ロックする (ロックを取得できるまで待つ)
+func lockFile(f *os.File) error {
+	overlapped := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, lockLength, 0, &overlapped)
+}
+
+// lockFileで取得したロックを解放する
+func unlockFile(f *os.File) error {
+	overlapped := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, lockLength, 0, &overlapped)
+}
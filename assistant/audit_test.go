@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// 監査ログのすべての行と記録を読み込む
+func readAuditLog(t *testing.T, path string) ([][]byte, []auditRecord) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer f.Close()
+	var lines [][]byte
+	var records []auditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		var record auditRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		lines = append(lines, line)
+		records = append(records, record)
+	}
+	return lines, records
+}
+
+// 各行のprevHashが直前の行のハッシュと一致することを確認する
+func checkAuditChain(t *testing.T, lines [][]byte, records []auditRecord) {
+	t.Helper()
+	for i, record := range records {
+		want := ""
+		if i > 0 {
+			hash := sha256.Sum256(lines[i-1])
+			want = hex.EncodeToString(hash[:])
+		}
+		if record.PrevHash != want {
+			t.Errorf("line %d: prevHash = %q, want %q", i+1, record.PrevHash, want)
+		}
+	}
+}
+
+// 監査ログを有効にし、テストの終了時に無効に戻す
+func enableAuditLog(t *testing.T, path string) {
+	t.Helper()
+	auditLog = newAuditLogger(path, "Gemini API")
+	t.Cleanup(func() { auditLog = nil })
+}
+
+func TestAuditLogFailureSkipsAPICall(t *testing.T) {
+	// ディレクトリと同じパスには書き込めない
+	enableAuditLog(t, t.TempDir())
+	streamer := &fakeStreamer{responses: []*genai.GenerateContentResponse{candidateResponse(genai.FinishReasonStop, &genai.Part{Text: "ok"})}}
+	_, _, _, err := runFakeStream(t, streamer)
+	if !isAuditLogError(err) {
+		t.Fatalf("err = %v, want an audit log error", err)
+	}
+	if streamer.calls != 0 {
+		t.Errorf("GenerateContentStream was called %d times, want 0", streamer.calls)
+	}
+}
+
+func TestAuditLogRecordsPendingAndResult(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	enableAuditLog(t, path)
+	streamer := &fakeStreamer{responses: []*genai.GenerateContentResponse{candidateResponse(genai.FinishReasonStop, &genai.Part{Text: "ok"})}}
+	if _, _, _, err := runFakeStream(t, streamer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines, records := readAuditLog(t, path)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[0].Status != auditStatusPending || records[1].Status != auditStatusCompleted {
+		t.Errorf("statuses = %q, %q, want %q, %q", records[0].Status, records[1].Status, auditStatusPending, auditStatusCompleted)
+	}
+	if records[0].RequestID == "" || records[0].RequestID != records[1].RequestID {
+		t.Errorf("request IDs = %q, %q, want the same non-empty ID", records[0].RequestID, records[1].RequestID)
+	}
+	checkAuditChain(t, lines, records)
+}
+
+func TestAuditLogConcurrentWritersKeepChain(t *testing.T) {
+	// 別のプロセスを模して、ミューテックスを共有しない複数のloggerから同時に追記する
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	loggers := []*auditLogger{newAuditLogger(path, "Gemini API"), newAuditLogger(path, "Gemini API"), newAuditLogger(path, "Gemini API"), newAuditLogger(path, "Gemini API")}
+	const perLogger = 100
+	var wg sync.WaitGroup
+	for _, logger := range loggers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perLogger {
+				if err := logger.record("id", LlmRequestConfig{Model: "test-model", InputText: "input"}, LLMMetadata{}, nil); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines, records := readAuditLog(t, path)
+	if len(records) != len(loggers)*perLogger {
+		t.Fatalf("got %d records, want %d", len(records), len(loggers)*perLogger)
+	}
+	checkAuditChain(t, lines, records)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/genai"
+)
+
+// -jsonl の入力レコード
+type jsonlInputRecord struct {
+	ID   json.RawMessage `json:"id"`
+	Text string          `json:"text"`
+}
+
+// -jsonl の出力レコード
+// idは入力の値 (文字列・数値など) をそのまま引き継ぐ
+type jsonlOutputRecord struct {
+	ID          json.RawMessage `json:"id"`
+	Translation string          `json:"translation"`
+	Tokens      int32           `json:"tokens"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// バッチ処理全体の集計
+type batchSummary struct {
+	Processed   int
+	Skipped     int
+	Failed      int
+	TotalTokens int32
+}
+
+// バッチ処理の集計を出力
+func (s batchSummary) print() {
+	fmt.Fprintln(os.Stderr, "==== Batch summary ====")
+	fmt.Fprintln(os.Stderr, "✓ Processed records:     ", s.Processed)
+	fmt.Fprintln(os.Stderr, "✓ Skipped records:       ", s.Skipped)
+	fmt.Fprintln(os.Stderr, "✓ Failed records:        ", s.Failed)
+	fmt.Fprintln(os.Stderr, "✓ Total token count:     ", s.TotalTokens)
+	fmt.Fprintln(os.Stderr, "=======================")
+}
+
+// 1レコード分の処理結果
+type batchResult struct {
+	lineNumber int
+	output     jsonlOutputRecord
+	err        error
+}
+
+// JSONLの各行 ({"id": ..., "text": ...}) を処理し、結果を入力と同じ順序でJSONLとして書き込む
+// 最大concurrency件のレコードを並行して処理し、一時的なエラーのレコードは待機してから最大retries回再試行する
+// 不正な行は行番号とともに標準エラー出力に報告してスキップし、API呼び出しに失敗したレコードはerrorフィールドに理由を書き込む
+// reqOpts.MaxTotalTokensが指定されている場合は、完了したレコードの累計トークン数が上限に達した時点で以降のレコードを開始せずに終了する
+func runJSONLBatch(ctx context.Context, streamer contentStreamer, r io.Reader, w io.Writer, task TaskDefinition, reqOpts requestOptions, concurrency int, retries int) (batchSummary, error) {
+	var summary batchSummary
+	// 監査ログへの記録に失敗した場合は以降のレコードを処理せずに終了する
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var auditErr error
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	// 完了順に関わらず入力順で書き込むため、レコードごとの結果チャネルを順番に受け取る
+	pending := make(chan chan batchResult, concurrency)
+	var writeErr error
+	var usedTokens atomic.Int64
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for resultCh := range pending {
+			result := <-resultCh
+			if result.err != nil && isAuditLogError(result.err) && auditErr == nil {
+				auditErr = result.err
+				cancel()
+			}
+			if result.err != nil {
+				fmt.Fprintln(os.Stderr, msgf("status.lineError", result.lineNumber, result.err))
+				result.output.Error = result.err.Error()
+				summary.Failed++
+			}
+			summary.Processed++
+			summary.TotalTokens += result.output.Tokens
+			if writeErr == nil {
+				if err := encoder.Encode(result.output); err != nil {
+					writeErr = errorf("err.writeResult", err)
+				}
+			}
+		}
+	}()
+
+	skipped, err := dispatchJSONLRecords(ctx, streamer, r, task, reqOpts, concurrency, retries, pending, &usedTokens)
+	close(pending)
+	<-writerDone
+	summary.Skipped = skipped
+
+	if auditErr != nil {
+		return summary, auditErr
+	}
+	if ctx.Err() != nil {
+		return summary, ctx.Err()
+	}
+	if err != nil {
+		return summary, err
+	}
+	return summary, writeErr
+}
+
+// 入力を1行ずつ読み込み、レコードごとにワーカーを起動して結果チャネルをpendingに送る
+// スキップした行数を返す
+func dispatchJSONLRecords(ctx context.Context, streamer contentStreamer, r io.Reader, task TaskDefinition, reqOpts requestOptions, concurrency int, retries int, pending chan<- chan batchResult, usedTokens *atomic.Int64) (int, error) {
+	semaphore := make(chan struct{}, concurrency)
+	skipped := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record jsonlInputRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			fmt.Fprintln(os.Stderr, msgf("warn.invalidRecord", lineNumber, err))
+			skipped++
+			continue
+		}
+		if len(record.ID) == 0 || strings.TrimSpace(record.Text) == "" {
+			fmt.Fprintln(os.Stderr, msgf("warn.missingIDOrText", lineNumber))
+			skipped++
+			continue
+		}
+
+		llmReqConfig, genaiConfig, err := createLLMConfigs(task, record.Text, nil, reqOpts)
+		if err != nil {
+			return skipped, err
+		}
+
+		// 同時に処理するレコード数を制限する
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			return skipped, ctx.Err()
+		}
+
+		// 処理中のレコードの分は含まれないため、上限はベストエフォート
+		if reqOpts.MaxTotalTokens > 0 && usedTokens.Load() >= int64(reqOpts.MaxTotalTokens) {
+			<-semaphore
+			fmt.Fprintln(os.Stderr, msgf("status.batchTokenBudget", usedTokens.Load(), reqOpts.MaxTotalTokens, lineNumber))
+			return skipped, nil
+		}
+
+		resultCh := make(chan batchResult, 1)
+		pending <- resultCh
+		go func(lineNumber int, id json.RawMessage) {
+			defer func() { <-semaphore }()
+			text, metadata, err := streamContentWithBackoff(ctx, streamer, llmReqConfig, genaiConfig, msgf("status.line", lineNumber), retries)
+			usedTokens.Add(int64(metadata.TotalTokenCount))
+			resultCh <- batchResult{
+				lineNumber: lineNumber,
+				output: jsonlOutputRecord{
+					ID:          id,
+					Translation: text,
+					Tokens:      metadata.TotalTokenCount,
+				},
+				err: err,
+			}
+		}(lineNumber, record.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		return skipped, errorf("err.readInput", err)
+	}
+	return skipped, nil
+}
+
+// streamContentを呼び出し、一時的なエラーの場合は待機時間を倍にしながら最大retries回再試行する
+// labelは再試行のメッセージの先頭に表示する処理対象の名前 (例: "3行目")
+func streamContentWithBackoff(ctx context.Context, streamer contentStreamer, llmReqConfig LlmRequestConfig, genaiConfig *genai.GenerateContentConfig, label string, retries int) (string, LLMMetadata, error) {
+	for attempt := 1; ; attempt++ {
+		var result bytes.Buffer
+		metadata, err := streamContent(ctx, streamer, llmReqConfig, genaiConfig, &result, io.Discard)
+		if err == nil || !isTransientError(err) || attempt > retries {
+			return result.String(), metadata, err
+		}
+
+		delay := retryDelay(attempt)
+		fmt.Fprintln(os.Stderr, msgf("status.retryLabeled", label, delay, attempt, retries, err))
+		if err := waitRetry(ctx, delay); err != nil {
+			return result.String(), metadata, err
+		}
+	}
+}
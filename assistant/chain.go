@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// -chainで指定されたタスク名 (カンマ区切り) をタスク定義の一覧に変換する
+func parseChain(value string) ([]TaskDefinition, error) {
+	var tasks []TaskDefinition
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		task, ok := getTaskDefinition(name)
+		if !ok {
+			return nil, errorf("err.invalidTask", name)
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) < 2 {
+		return nil, errorf("err.invalidChain", value)
+	}
+	return tasks, nil
+}
+
+// 連鎖の各ステージで使うリクエストのオプションを返す
+// -tone、-bullets、-ground、用語集はそれらに対応するステージにのみ適用する
+func stageRequestOptions(task TaskDefinition, reqOpts requestOptions) requestOptions {
+	if len(task.Tones) == 0 {
+		reqOpts.Tone = ""
+	}
+	if task.BulletsInstruction == "" {
+		reqOpts.Bullets = nil
+	}
+	if !task.AllowGrounding {
+		reqOpts.Grounding = false
+	}
+	if !task.UsesGlossary {
+		reqOpts.Glossary = ""
+	}
+	return reqOpts
+}
+
+// 前のステージの出力から次のステージの入力を作る
+// translateタスクの出力はCONTEXTを除いたENGLISHセクションのみを渡す
+func stageOutputForNext(task TaskDefinition, output string) string {
+	if task.Name == "translate" {
+		return parseTranslationOutput(output).English
+	}
+	return strings.TrimSpace(output)
+}
+
+// タスクを順に実行し、各ステージの出力を次のステージの入力にする
+// 途中のステージの出力は次のステージに渡すだけで表示せず、最後のステージの出力を標準出力にストリーミング表示する
+// ステージごとのメタデータと全体の合計を標準エラー出力に表示し、いずれかのステージでエラーになった場合はそこで中止する
+func runChain(ctx context.Context, streamer contentStreamer, apiMethod string, tasks []TaskDefinition, reqOpts requestOptions, charsPerStep int, delay time.Duration, thoughtColor *color.Color, showSpinner bool, input string) error {
+	var usage sessionUsage
+	var apiCallTime time.Duration
+	for i, task := range tasks {
+		fmt.Fprintf(os.Stderr, "==== Stage %d/%d: %s ====\n", i+1, len(tasks), task.Name)
+		llmReqConfig, genaiConfig, err := createLLMConfigs(task, input, nil, stageRequestOptions(task, reqOpts))
+		if err != nil {
+			return errorf("err.chainStage", i+1, task.Name, err)
+		}
+
+		// 最後のステージのみ標準出力に表示し、途中のステージは思考プロセスも表示しない
+		last := i == len(tasks)-1
+		var result bytes.Buffer
+		var output *typewriter
+		var out, thoughtOut io.Writer = &result, io.Discard
+		if last {
+			output = newTypewriter(os.Stdout, charsPerStep, delay)
+			out, thoughtOut = output, colorWriter{output, thoughtColor}
+		}
+		var spin *spinner
+		if showSpinner {
+			spin = startSpinner()
+			out, thoughtOut = spinnerStopWriter{out, spin}, spinnerStopWriter{thoughtOut, spin}
+		}
+		metadata, err := streamContent(ctx, streamer, llmReqConfig, genaiConfig, out, thoughtOut)
+		spin.Stop()
+		if output != nil {
+			output.Close()
+		}
+		if err != nil {
+			return errorf("err.chainStage", i+1, task.Name, err)
+		}
+
+		usage.add(metadata)
+		apiCallTime += metadata.APICallTime
+		printMetadata(metadata, apiMethod, task.Name)
+		input = stageOutputForNext(task, result.String())
+	}
+
+	fmt.Fprintln(os.Stderr, "==== Chain total ====")
+	table := tabwriter.NewWriter(os.Stderr, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(table, "✓ Stages:\t%d\n", usage.Turns)
+	fmt.Fprintf(table, "✓ API call time:\t%v\n", apiCallTime)
+	fmt.Fprintf(table, "✓ Prompt token count:\t%d\n", usage.PromptTokenCount)
+	fmt.Fprintf(table, "✓ Candidate token count:\t%d\n", usage.CandidatesTokenCount)
+	fmt.Fprintf(table, "✓ Thoughts token count:\t%d\n", usage.ThoughtsTokenCount)
+	fmt.Fprintf(table, "✓ Total token count:\t%d\n", usage.TotalTokenCount)
+	table.Flush()
+	fmt.Fprintln(os.Stderr, "=====================")
+	return nil
+}
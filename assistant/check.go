@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var (
+	checkOKMark   = color.New(color.FgGreen).Sprint("✓")
+	checkFailMark = color.New(color.FgRed).Sprint("✗")
+)
+
+// -checkの各ステップの結果を表示する
+func printCheckResult(ok bool, label string, detail string) {
+	mark := checkOKMark
+	if !ok {
+		mark = checkFailMark
+	}
+	fmt.Printf("%s %-22s %s\n", mark, label+":", detail)
+}
+
+// 設定、認証情報、モデルを順に確認し、終了コードを返す
+// 生成リクエストは送信せず、最初に失敗したステップで終了する
+func runCheck(opts cliOptions) int {
+	// 設定ファイル
+	settingsPath, err := getSettingsPath()
+	if err != nil {
+		printCheckResult(false, "Settings", err.Error())
+		return exitUsage
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		printCheckResult(false, "Settings", err.Error())
+		return exitUsage
+	}
+	if settings == nil {
+		printCheckResult(false, "Settings", msgf("check.settingsNotFound", settingsPath))
+		return exitUsage
+	}
+	// 全ユーザー共通の設定ファイルがある場合は、読み込んだファイルを優先度の低い順に表示する
+	var settingsFiles []string
+	for _, path := range []string{systemSettingsPath, settingsPath} {
+		if _, err := os.Stat(path); err == nil {
+			settingsFiles = append(settingsFiles, path)
+		}
+	}
+	printCheckResult(true, "Settings", strings.Join(settingsFiles, " + "))
+
+	// プロファイルとAPIメソッド
+	_, profileName, err := settings.resolveProfile(opts.Profile)
+	if err != nil {
+		printCheckResult(false, "Profile", err.Error())
+		return exitUsage
+	}
+	_, profile, err := selectProfile(opts, settings)
+	if err != nil {
+		printCheckResult(false, "Profile", err.Error())
+		return exitUsage
+	}
+	printCheckResult(true, "Profile", profileName)
+	printCheckResult(profile.APIMethod == "apiKey" || profile.APIMethod == "vertexAI", "API method", profile.APIMethod)
+
+	// 認証情報
+	switch profile.APIMethod {
+	case "apiKey":
+		keys := profile.APIKeyConfig.candidates()
+		for i, key := range keys {
+			label := "API key"
+			if i > 0 {
+				label = fmt.Sprintf("API key (fallback %d)", i)
+			}
+			if _, err := key.resolveAPIKey(); err != nil {
+				printCheckResult(false, label, err.Error())
+				if i == 0 {
+					return exitAuth
+				}
+				continue
+			}
+			printCheckResult(true, label, msg("check.configured"))
+		}
+	case "vertexAI":
+		if profile.VertexAIConfig.Project == "" || profile.VertexAIConfig.Location == "" {
+			printCheckResult(false, "Vertex AI", msg("check.vertexNotConfigured"))
+			return exitAuth
+		}
+		printCheckResult(true, "Vertex AI", fmt.Sprintf("project=%s location=%s", profile.VertexAIConfig.Project, profile.VertexAIConfig.Location))
+	default:
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// クライアントの初期化と、モデル一覧の取得による認証の確認
+	client, apiMethod, err := initClient(ctx, profile)
+	if err != nil {
+		printCheckResult(false, "Client", err.Error())
+		return exitAuth
+	}
+	printCheckResult(true, "Client", apiMethod)
+
+	models, err := fetchAvailableModels(ctx, client)
+	if err != nil {
+		printCheckResult(false, "Authentication", err.Error())
+		if code := exitCodeForError(err); code != exitGeneral {
+			return code
+		}
+		return exitAuth
+	}
+	printCheckResult(true, "Authentication", msgf("check.modelsListed", len(models)))
+
+	// モデル
+	requested := shortModelName(opts.ModelName)
+	for _, m := range models {
+		if shortModelName(m.Name) == requested {
+			printCheckResult(true, "Model", opts.ModelName)
+			return exitOK
+		}
+	}
+	printCheckResult(false, "Model", msgf("check.modelUnavailable", opts.ModelName))
+	return exitModelNotFound
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// 思考プロセスのテキストのデフォルトの色
+const defaultThoughtColor = "blue"
+
+// 思考プロセスのテキストに指定できる色
+var thoughtColors = map[string]*color.Color{
+	"blue":    color.New(color.FgBlue),
+	"green":   color.New(color.FgGreen),
+	"cyan":    color.New(color.FgCyan),
+	"magenta": color.New(color.FgMagenta),
+	"yellow":  color.New(color.FgYellow),
+	"red":     color.New(color.FgRed),
+	"white":   color.New(color.FgWhite),
+	"gray":    color.New(color.FgHiBlack),
+	"grey":    color.New(color.FgHiBlack),
+	"dim":     color.New(color.Faint),
+	"none":    color.New(color.Reset),
+}
+
+// 色の名前から色を返す
+// 空の場合はデフォルトの色を使い、不明な名前の場合は警告を表示してデフォルトの色を使う
+func resolveThoughtColor(name string) *color.Color {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" {
+		normalized = defaultThoughtColor
+	}
+	c, ok := thoughtColors[normalized]
+	if !ok {
+		printNotice(msgf("warn.unknownColor", name, defaultThoughtColor))
+		return thoughtColors[defaultThoughtColor]
+	}
+	return c
+}
+
+// 書き込まれたテキストを指定した色で出力するio.Writer
+type colorWriter struct {
+	w     io.Writer
+	color *color.Color
+}
+
+func (cw colorWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(cw.w, cw.color.Sprint(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
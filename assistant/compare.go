@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// -compareで指定されたモデル名 (カンマ区切り) を一覧に変換する
+func parseCompareModels(value string) ([]string, error) {
+	var models []string
+	for _, model := range strings.Split(value, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	if len(models) < 2 {
+		return nil, errorf("err.invalidCompare", value)
+	}
+	return models, nil
+}
+
+// 比較する1つのモデルの結果
+type compareResult struct {
+	Model    string
+	Output   string
+	Metadata LLMMetadata
+	Err      error
+}
+
+// 同じ入力を各モデルで順に実行し、出力とメタデータを並べて比較する
+// API呼び出し時間が互いに影響しないよう、モデルは1つずつ実行する
+// 各モデルの出力は溜めておき、すべて終わってから標準出力にまとめて表示し、比較の表を標準エラー出力に表示する
+// いずれかのモデルでエラーになった場合も残りのモデルを実行し、最後にエラーを返す (監査ログのエラーの場合はそこで中止する)
+func runCompare(ctx context.Context, streamer contentStreamer, task TaskDefinition, models []string, reqOpts requestOptions, image *imageInput, input string, retries int, out io.Writer) error {
+	results := make([]compareResult, 0, len(models))
+	for i, model := range models {
+		fmt.Fprintf(os.Stderr, "==== Model %d/%d: %s ====\n", i+1, len(models), model)
+		modelOpts := reqOpts
+		modelOpts.ModelName = model
+		result := compareResult{Model: model}
+		llmReqConfig, genaiConfig, err := createLLMConfigs(task, input, image, modelOpts)
+		if err == nil {
+			result.Output, result.Metadata, err = streamContentWithBackoff(ctx, streamer, llmReqConfig, genaiConfig, model, retries)
+		}
+		if err != nil {
+			if isAuditLogError(err) || ctx.Err() != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s: %v\n", model, err)
+			result.Err = err
+		}
+		results = append(results, result)
+	}
+
+	for _, result := range results {
+		fmt.Fprintf(out, "==== %s ====\n", result.Model)
+		if result.Err != nil {
+			fmt.Fprintf(out, "(%s)\n\n", msgf("status.compareError", result.Err))
+			continue
+		}
+		fmt.Fprintln(out, strings.TrimRight(result.Output, "\n"))
+		fmt.Fprintln(out)
+	}
+	printCompareTable(os.Stderr, results)
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errorf("err.compareFailed", len(results), failed)
+	}
+	return nil
+}
+
+// モデルごとのAPI呼び出し時間、トークン数、入力の料金の見積もりを表にして出力する
+func printCompareTable(w io.Writer, results []compareResult) {
+	fmt.Fprintln(w, "==== Compare ====")
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "Model\tAPI call time\tPrompt\tCandidates\tThoughts\tTotal\tEst. input cost")
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(table, "%s\t(error)\t-\t-\t-\t-\t-\n", result.Model)
+			continue
+		}
+		metadata := result.Metadata
+		cost := "-"
+		if usd, ok := estimateInputCost(result.Model, metadata.PromptTokenCount); ok {
+			cost = fmt.Sprintf("$%.6f", usd)
+		}
+		fmt.Fprintf(table, "%s\t%v\t%d\t%d\t%d\t%d\t%s\n", result.Model, metadata.APICallTime, metadata.PromptTokenCount, metadata.CandidatesTokenCount, metadata.ThoughtsTokenCount, metadata.TotalTokenCount, cost)
+	}
+	table.Flush()
+	fmt.Fprintln(w, "=================")
+}
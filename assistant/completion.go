@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 補完スクリプトに含めるフラグ
+type completionFlag struct {
+	Name   string
+	Usage  string
+	IsBool bool
+}
+
+// ファイルパスを値に取るフラグ (補完時にファイル名を候補にする)
+var fileValueFlags = []string{"file", "image", "output", "config", "jsonl", "schema", "stats-json"}
+
+// タスク名とエイリアスを返す
+func completionTaskNames() []string {
+	var names []string
+	for _, task := range taskDefinitions {
+		names = append(names, task.Name)
+	}
+	for alias := range taskAliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 指定したシェル (bash|zsh|fish) 向けの補完スクリプトを書き込む
+// -model の候補にはキャッシュ済みのモデル一覧を使う (キャッシュがない場合は候補なし)
+func writeCompletion(w io.Writer, shell string, program string, flags []completionFlag) error {
+	tasks := completionTaskNames()
+	models := cachedModelNames()
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, program, flags, tasks, models)
+	case "zsh":
+		// zshではbashの補完スクリプトをbashcompinitで読み込む
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(w, program, flags, tasks, models)
+	case "fish":
+		writeFishCompletion(w, program, flags, tasks, models)
+	default:
+		return errorf("err.invalidCompletion", shell)
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, program string, flags []completionFlag, tasks, models []string) {
+	funcName := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+	var flagWords, fileFlagPatterns []string
+	for _, f := range flags {
+		flagWords = append(flagWords, "-"+f.Name)
+		if slices.Contains(fileValueFlags, f.Name) {
+			fileFlagPatterns = append(fileFlagPatterns, "-"+f.Name, "--"+f.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "%s() {\n", funcName)
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `	case "$prev" in`)
+	fmt.Fprintf(w, "\t-task|--task) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(tasks, " "))
+	fmt.Fprintf(w, "\t-model|--model) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(models, " "))
+	fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(fileFlagPatterns, "|"))
+	fmt.Fprintln(w, `	esac`)
+	fmt.Fprintln(w, `	if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(flagWords, " "))
+	fmt.Fprintln(w, `	fi`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintf(w, "complete -o default -F %s %s\n", funcName, program)
+}
+
+func writeFishCompletion(w io.Writer, program string, flags []completionFlag, tasks, models []string) {
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c %s -o %s -d %s", program, f.Name, fishQuote(f.Usage))
+		switch {
+		case f.Name == "task":
+			line += " -x -a " + fishQuote(strings.Join(tasks, " "))
+		case f.Name == "model":
+			line += " -x -a " + fishQuote(strings.Join(models, " "))
+		case slices.Contains(fileValueFlags, f.Name):
+			line += " -r -F"
+		case !f.IsBool:
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// fishの単一引用符で囲んだ文字列を返す
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
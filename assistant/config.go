@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Vertex AI接続の設定
@@ -18,22 +21,228 @@ type VertexAIConfig struct {
 // APIキー接続の設定
 type APIKeyConfig struct {
 	APIKeyEnvVarName string `json:"apiKeyEnvVarName"`
+	APIKeyFile       string `json:"apiKeyFile,omitempty"` // 環境変数からキーを取得できない場合に読み込むファイル
+	// クォータ超過 (429) の場合に順に切り替えて使う予備のAPIキー
+	Fallbacks []APIKeyConfig `json:"fallbacks,omitempty"`
 }
 
-// アプリケーションの全体設定
-type Settings struct {
+// 使用するAPIキーの取得元を優先順に返す (先頭が主キー、以降が予備のキー)
+func (c APIKeyConfig) candidates() []APIKeyConfig {
+	primary := c
+	primary.Fallbacks = nil
+	return append([]APIKeyConfig{primary}, c.Fallbacks...)
+}
+
+// APIキーを環境変数またはファイルから取得する
+// 環境変数名が空か環境変数が未設定の場合はファイルから読み込む
+func (c APIKeyConfig) resolveAPIKey() (string, error) {
+	if c.APIKeyEnvVarName != "" {
+		if apiKey := os.Getenv(c.APIKeyEnvVarName); apiKey != "" {
+			return apiKey, nil
+		}
+	}
+
+	if c.APIKeyFile != "" {
+		data, err := os.ReadFile(c.APIKeyFile)
+		if err != nil {
+			return "", errorf("err.readAPIKeyFile", c.APIKeyFile, err)
+		}
+		apiKey := strings.TrimSpace(string(data))
+		if apiKey == "" {
+			return "", errorf("err.emptyAPIKeyFile", c.APIKeyFile)
+		}
+		return apiKey, nil
+	}
+
+	if c.APIKeyEnvVarName == "" {
+		return "", errorf("err.apiKeySourceMissing")
+	}
+	return "", errorf("err.apiKeyEnvEmpty", c.APIKeyEnvVarName)
+}
+
+// API接続のプロファイル
+type Profile struct {
 	APIMethod      string         `json:"apiMethod"` // "apiKey" または "vertexAI"
 	VertexAIConfig VertexAIConfig `json:"vertexAiConfig"`
 	APIKeyConfig   APIKeyConfig   `json:"apiKeyConfig"`
+	BaseURL        string         `json:"baseUrl,omitempty"`    // Gemini APIのベースURL (プロキシや互換エンドポイント向け、apiKeyのみ)
+	APIVersion     string         `json:"apiVersion,omitempty"` // APIのバージョン (例: v1、v1beta、空の場合はSDKのデフォルト)
+}
+
+// 旧形式 (プロファイル導入前) の設定ファイルを読み込んだ際のプロファイル名
+const legacyProfileName = "default"
+
+// ストリーミング表示 (typewriter) の設定
+// 0の場合はデフォルト値を使う
+type StreamingSettings struct {
+	CharsPerStep  int `json:"charsPerStep,omitempty"`
+	MillisPerChar int `json:"millisPerChar,omitempty"`
+}
+
+// ストリーミング表示のデフォルト値
+const (
+	defaultCharsPerStep  = 5
+	defaultMillisPerChar = 15
+)
+
+// アプリケーションの全体設定
+type Settings struct {
+	DefaultProfile string              `json:"defaultProfile"`
+	Profiles       map[string]*Profile `json:"profiles"`
+	Streaming      StreamingSettings   `json:"streaming"`
+	ThoughtColor   string              `json:"thoughtColor,omitempty"` // 思考プロセスのテキストの色 (デフォルト: blue)
+	Retries        *int                `json:"retries,omitempty"`      // 一時的なエラーの場合に再試行する最大回数 (デフォルト: 3、0で再試行しない)
+	SaveHistory    bool                `json:"saveHistory,omitempty"`  // 実行ごとに入力と出力を履歴ファイルに記録するか
+	GlossaryPath   string              `json:"glossaryPath,omitempty"` // 用語集を使うタスク (expand) の用語集ファイルのパス (-glossary が優先)
+	DefaultTask    string              `json:"defaultTask,omitempty"`  // タスクを指定しない場合に使うタスク (-task、-chainの指定が優先)
+	APIVersion     string              `json:"apiVersion,omitempty"`   // プロファイルでapiVersionを指定しない場合に使うAPIのバージョン
+	SystemPrefix   string              `json:"systemPrefix,omitempty"` // すべてのタスクのシステム指示の先頭に加える指示 (-system-prefix が優先)
+	// 思考関連のフラグが指定されていない場合の思考の設定 (フラグの指定が常に優先)
+	DefaultThinking       bool   `json:"defaultThinking,omitempty"`       // trueの場合は思考を有効にする (falseの場合はタスクのデフォルトに従う)
+	DefaultThinkingBudget int32  `json:"defaultThinkingBudget,omitempty"` // -think-budget 未指定時の思考予算 (Gemini 3以外、0の場合は1024)
+	DefaultThinkingLevel  string `json:"defaultThinkingLevel,omitempty"`  // -think-level 未指定時の思考レベル (Gemini 3、minimal|low|medium|high)
+	// 指定したモデルが見つからない (404) 場合に順に試すモデル (-model-fallback が優先)
+	ModelFallbacks []string `json:"modelFallbacks,omitempty"`
+	// リクエストごとの監査ログを追記するファイルのパス (空の場合は記録しない、-audit-log が優先)
+	AuditLogPath string `json:"auditLogPath,omitempty"`
+	// 1秒あたりの最大リクエスト数 (0または未設定の場合は無制限、-rps が優先)
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	// 入力がこの文字数を超える場合、端末から実行されていれば送信前に確認する (0または未設定の場合は確認しない、-yes で省略)
+	ConfirmOverChars int `json:"confirmOverChars,omitempty"`
+	// 翻訳先の言語 (例: "en") ごとに翻訳タスクのシステム指示へ追加する指示
+	TargetLanguageInstructions map[string]string `json:"targetLanguageInstructions,omitempty"`
+}
+
+// フォールバックのモデルを決定する (フラグの指定を優先し、次に設定ファイルの値を使う)
+func resolveModelFallbacks(flagModels []string, settings *Settings) []string {
+	if len(flagModels) > 0 {
+		return flagModels
+	}
+	if settings != nil {
+		return settings.ModelFallbacks
+	}
+	return nil
+}
+
+// 翻訳先の言語に固有の追加指示を返す (言語が空か、指示が設定されていない場合は空文字列)
+func (s *Settings) targetLanguageInstruction(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return ""
+	}
+	for key, instruction := range s.TargetLanguageInstructions {
+		if strings.ToLower(strings.TrimSpace(key)) == language {
+			return instruction
+		}
+	}
+	return ""
+}
+
+// ストリーミング表示の設定を決定する
+// フラグの値 (0以外) を優先し、次に設定ファイルの値、どちらもなければデフォルト値を使う
+func (s StreamingSettings) resolve(flagCharsPerStep, flagMillisPerChar int) (charsPerStep int, delay time.Duration, err error) {
+	charsPerStep, err = firstPositive("charsPerStep", flagCharsPerStep, s.CharsPerStep, defaultCharsPerStep)
+	if err != nil {
+		return 0, 0, err
+	}
+	millisPerChar, err := firstPositive("millisPerChar", flagMillisPerChar, s.MillisPerChar, defaultMillisPerChar)
+	if err != nil {
+		return 0, 0, err
+	}
+	return charsPerStep, time.Duration(millisPerChar) * time.Millisecond, nil
+}
+
+// 0 (未設定) でない最初の値を返す。負の値が設定されている場合はエラーを返す
+func firstPositive(name string, values ...int) (int, error) {
+	for _, v := range values {
+		if v < 0 {
+			return 0, errorf("err.nonPositive", name, v)
+		}
+		if v > 0 {
+			return v, nil
+		}
+	}
+	return 0, nil
 }
 
+// 文字列の設定値に含まれる環境変数 ($VAR, ${VAR}) を展開したプロファイルのコピーを返す
+// 設定ファイルを保存する際に展開後の値で上書きしないよう、元のプロファイルは変更しない
+func (p Profile) expandEnv() *Profile {
+	p.VertexAIConfig.Project = os.ExpandEnv(p.VertexAIConfig.Project)
+	p.VertexAIConfig.Location = os.ExpandEnv(p.VertexAIConfig.Location)
+	p.APIKeyConfig.APIKeyFile = os.ExpandEnv(p.APIKeyConfig.APIKeyFile)
+	p.APIKeyConfig.Fallbacks = slices.Clone(p.APIKeyConfig.Fallbacks)
+	for i := range p.APIKeyConfig.Fallbacks {
+		p.APIKeyConfig.Fallbacks[i].APIKeyFile = os.ExpandEnv(p.APIKeyConfig.Fallbacks[i].APIKeyFile)
+	}
+	p.BaseURL = os.ExpandEnv(p.BaseURL)
+	return &p
+}
+
+// APIメソッドをbackendに置き換えたプロファイルのコピーを返す
+// 置き換え先の接続情報が設定されていない場合はエラーを返す
+func (p Profile) withBackend(backend string) (*Profile, error) {
+	p.APIMethod = backend
+	switch backend {
+	case "apiKey":
+		if p.APIKeyConfig.APIKeyEnvVarName == "" && p.APIKeyConfig.APIKeyFile == "" {
+			return nil, errorf("err.apiKeyBackendNotConfigured")
+		}
+	case "vertexAI":
+		if p.VertexAIConfig.Project == "" || p.VertexAIConfig.Location == "" {
+			return nil, errorf("err.vertexBackendNotConfigured")
+		}
+	}
+	return &p, nil
+}
+
+// プロファイル名からプロファイルを返す
+// 名前が空の場合はデフォルトプロファイルを返す
+// 返すプロファイルは設定値の環境変数を展開したコピー
+func (s *Settings) resolveProfile(name string) (*Profile, string, error) {
+	if strings.TrimSpace(name) == "" {
+		name = s.DefaultProfile
+	}
+	if name == "" {
+		return nil, "", errorf("err.noDefaultProfile")
+	}
+	profile, ok := s.Profiles[name]
+	if !ok || profile == nil {
+		return nil, "", errorf("err.profileNotFound", name, strings.Join(s.profileNames(), ", "))
+	}
+	return profile.expandEnv(), name, nil
+}
+
+// 登録済みのプロファイル名をソートして返す
+func (s *Settings) profileNames() []string {
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// -configフラグで指定された設定ファイルのパス (空の場合はデフォルトのパスを使う)
+var settingsPathOverride string
+
 // 設定ファイルのパスを返す
+// -configフラグの指定があればそれを優先し、なければ $XDG_CONFIG_HOME (未設定時は ~/.config) 配下を使う
+// XDG Base Directoryの仕様に従い、$XDG_CONFIG_HOME が絶対パスでない場合は無視する
 func getSettingsPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("ホームディレクトリの取得に失敗しました: %w", err)
+	if settingsPathOverride != "" {
+		return settingsPathOverride, nil
 	}
-	return filepath.Join(homeDir, ".config", "llm-assistant", "settings.json"), nil
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configDir) {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errorf("err.homeDir", err)
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, "llm-assistant", "settings.json"), nil
 }
 
 // 設定ファイルディレクトリを作成する
@@ -46,29 +255,100 @@ func ensureSettingsDir() error {
 	return os.MkdirAll(settingsDir, 0755)
 }
 
-// 設定ファイルから設定を読み込む
+// 全ユーザー共通の設定ファイルのパス (管理者が用意するデフォルトの設定)
+const systemSettingsPath = "/etc/llm-assistant/settings.json"
+
+// 全ユーザー共通の設定ファイルとユーザーの設定ファイルから設定を読み込む
+// 両方ある場合は、共通の設定にユーザーの設定ファイルにある項目を上書きする (プロファイルは名前ごとに置き換える)
+// どちらも存在しない場合はnilを返す
 func loadSettings() (*Settings, error) {
 	settingsPath, err := getSettingsPath()
 	if err != nil {
 		return nil, err
 	}
+	return loadSettingsFiles(systemSettingsPath, settingsPath)
+}
+
+// ユーザーの設定ファイルのみから設定を読み込む (保存し直す場合に共通の設定を書き込まないようにするため)
+// ファイルが存在しない場合はnilを返す
+func loadUserSettings() (*Settings, error) {
+	settingsPath, err := getSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	return loadSettingsFiles(settingsPath)
+}
 
-	// ファイルが存在しない場合はnilを返す
-	if _, err := os.Stat(settingsPath); os.IsNotExist(err) {
+// 設定ファイルを順に読み込み、後のファイルの項目で上書きした設定を返す
+// いずれのファイルも存在しない場合はnilを返す
+func loadSettingsFiles(paths ...string) (*Settings, error) {
+	var settings Settings
+	found := false
+	for _, path := range paths {
+		ok, err := readSettingsFile(path, &settings)
+		if err != nil {
+			return nil, err
+		}
+		found = found || ok
+	}
+	if !found {
 		return nil, nil
 	}
 
-	data, err := os.ReadFile(settingsPath)
+	if settings.DefaultThinkingLevel != "" {
+		if _, err := parseThinkingLevel(settings.DefaultThinkingLevel); err != nil {
+			return nil, errorf("err.invalidDefaultThinkingLevel", settings.DefaultThinkingLevel)
+		}
+	}
+	if settings.DefaultThinkingBudget < 0 {
+		return nil, errorf("err.negativeDefaultThinkingBudget", settings.DefaultThinkingBudget)
+	}
+	if settings.DefaultTask != "" {
+		if _, ok := getTaskDefinition(settings.DefaultTask); !ok {
+			return nil, errorf("err.invalidDefaultTask", settings.DefaultTask)
+		}
+	}
+	if settings.ConfirmOverChars < 0 {
+		return nil, errorf("err.negativeConfirmOverChars", settings.ConfirmOverChars)
+	}
+
+	return &settings, nil
+}
+
+// 設定ファイルを読み込み、ファイルにある項目でsettingsを上書きする
+// ファイルが存在しない場合はfalseを返す
+func readSettingsFile(path string, settings *Settings) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("設定ファイルの読み込みに失敗しました: %w", err)
+		return false, errorf("err.readSettings", path, err)
 	}
 
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return nil, fmt.Errorf("設定ファイルの解析に失敗しました: %w", err)
+	var fileSettings Settings
+	if err := json.Unmarshal(data, &fileSettings); err != nil {
+		return false, errorf("err.parseSettings", path, err)
+	}
+	if err := json.Unmarshal(data, settings); err != nil {
+		return false, errorf("err.parseSettings", path, err)
 	}
 
-	return &settings, nil
+	// 旧形式の設定ファイルの場合は単一のプロファイルとして扱う
+	if len(fileSettings.Profiles) == 0 {
+		var legacy Profile
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return false, errorf("err.parseSettings", path, err)
+		}
+		if legacy.APIMethod != "" {
+			if settings.Profiles == nil {
+				settings.Profiles = map[string]*Profile{}
+			}
+			settings.Profiles[legacyProfileName] = &legacy
+			settings.DefaultProfile = legacyProfileName
+		}
+	}
+	return true, nil
 }
 
 // 設定をファイルに保存する
@@ -84,79 +364,146 @@ func saveSettings(settings *Settings) error {
 
 	data, err := json.MarshalIndent(settings, "", "  ")
 	if err != nil {
-		return fmt.Errorf("設定のシリアライズに失敗しました: %w", err)
+		return errorf("err.serializeSettings", err)
 	}
 
 	if err := os.WriteFile(settingsPath, data, 0644); err != nil {
-		return fmt.Errorf("設定ファイルの保存に失敗しました: %w", err)
+		return errorf("err.saveSettings", err)
 	}
 
 	return nil
 }
 
-// 対話型で設定をセットアップする
-func setupInteractive() (*Settings, error) {
-	fmt.Println("設定ファイルが見つかりません。対話形式で設定を行います。")
+// 対話型でプロファイルを作成し、設定に追加して保存する
+// existingがnilの場合は新しい設定を作成する
+func setupInteractive(existing *Settings) (*Settings, error) {
+	if existing == nil {
+		fmt.Println(msg("setup.notFound"))
+	}
 	scanner := bufio.NewScanner(os.Stdin)
 
+	settings := existing
+	if settings == nil {
+		settings = &Settings{}
+	}
+	if settings.Profiles == nil {
+		settings.Profiles = map[string]*Profile{}
+	}
+
+	// プロファイル名の入力
+	fmt.Println()
+	if len(settings.Profiles) > 0 {
+		fmt.Println(msgf("setup.registeredProfiles", strings.Join(settings.profileNames(), ", ")))
+	}
+	fmt.Print(msgf("setup.profileName", legacyProfileName))
+	scanner.Scan()
+	profileName := strings.TrimSpace(scanner.Text())
+	if profileName == "" {
+		profileName = legacyProfileName
+	}
+
 	// APIメソッドの選択
 	fmt.Println()
-	fmt.Println("使用するAPIメソッドを選択してください:")
-	fmt.Println("1. APIキーを使用 (Gemini API)")
-	fmt.Println("2. Vertex AIを使用")
-	fmt.Print("選択してください (1または2): ")
+	fmt.Println(msg("setup.apiMethod"))
+	fmt.Println(msg("setup.apiMethodKey"))
+	fmt.Println(msg("setup.apiMethodVertex"))
+	fmt.Print(msg("setup.choose"))
 
 	scanner.Scan()
 	choice := strings.TrimSpace(scanner.Text())
 
-	settings := &Settings{}
+	profile := &Profile{}
 
 	switch choice {
 	case "1":
-		settings.APIMethod = "apiKey"
+		profile.APIMethod = "apiKey"
 
-		// APIキー環境変数名の設定
-		fmt.Print("APIキーが設定されている環境変数名を入力してください (デフォルト: API_KEY_GOOGLE): ")
+		// APIキーの取得元の選択
+		fmt.Println(msg("setup.keySource"))
+		fmt.Println(msg("setup.keySourceEnv"))
+		fmt.Println(msg("setup.keySourceFile"))
+		fmt.Print(msg("setup.chooseDefault1"))
 		scanner.Scan()
-		envVarName := strings.TrimSpace(scanner.Text())
-		if envVarName == "" {
-			envVarName = "API_KEY_GOOGLE"
+		source := strings.TrimSpace(scanner.Text())
+
+		switch source {
+		case "", "1":
+			// APIキー環境変数名の設定
+			fmt.Print(msg("setup.envVarName"))
+			scanner.Scan()
+			envVarName := strings.TrimSpace(scanner.Text())
+			if envVarName == "" {
+				envVarName = "API_KEY_GOOGLE"
+			}
+			profile.APIKeyConfig.APIKeyEnvVarName = envVarName
+
+		case "2":
+			// APIキーファイルのパスの設定
+			fmt.Print(msg("setup.keyFile"))
+			scanner.Scan()
+			keyFile := strings.TrimSpace(scanner.Text())
+			if keyFile == "" {
+				return nil, errorf("setup.keyFileRequired")
+			}
+			profile.APIKeyConfig.APIKeyFile = keyFile
+
+		default:
+			return nil, errorf("setup.invalidChoice", source)
 		}
-		settings.APIKeyConfig.APIKeyEnvVarName = envVarName
 
 	case "2":
-		settings.APIMethod = "vertexAI"
+		profile.APIMethod = "vertexAI"
 
 		// プロジェクトIDの設定
-		fmt.Print("Google CloudプロジェクトIDを入力してください: ")
+		fmt.Print(msg("setup.project"))
 		scanner.Scan()
 		project := strings.TrimSpace(scanner.Text())
 		if project == "" {
-			return nil, fmt.Errorf("プロジェクトIDは必須です")
+			return nil, errorf("setup.projectRequired")
 		}
-		settings.VertexAIConfig.Project = project
+		profile.VertexAIConfig.Project = project
 
 		// リージョンの設定
-		fmt.Print("Vertex AIのリージョンを入力してください (デフォルト: asia-northeast1): ")
+		fmt.Print(msg("setup.location"))
 		scanner.Scan()
 		location := strings.TrimSpace(scanner.Text())
 		if location == "" {
 			location = "asia-northeast1"
 		}
-		settings.VertexAIConfig.Location = location
+		profile.VertexAIConfig.Location = location
 
 	default:
-		return nil, fmt.Errorf("無効な選択です: %s", choice)
+		return nil, errorf("setup.invalidChoice", choice)
+	}
+
+	settings.Profiles[profileName] = profile
+
+	// デフォルトプロファイルの設定
+	if settings.DefaultProfile == "" || len(settings.Profiles) == 1 {
+		settings.DefaultProfile = profileName
+	} else if settings.DefaultProfile != profileName {
+		fmt.Print(msgf("setup.makeDefault", settings.DefaultProfile))
+		scanner.Scan()
+		answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if answer == "y" || answer == "yes" {
+			settings.DefaultProfile = profileName
+		}
 	}
 
 	// 設定を保存
 	if err := saveSettings(settings); err != nil {
-		return nil, fmt.Errorf("設定の保存に失敗しました: %w", err)
+		return nil, errorf("setup.saveFailed", err)
+	}
+
+	settingsPath, err := getSettingsPath()
+	if err != nil {
+		return nil, err
 	}
 
 	fmt.Println()
-	fmt.Printf("設定を ~/.config/llm-assistant/settings.json に保存しました。\n")
-	fmt.Printf("選択したAPIメソッド: %s\n", settings.APIMethod)
+	fmt.Println(msgf("setup.saved", settingsPath))
+	fmt.Println(msgf("setup.profile", profileName, profile.APIMethod))
+	fmt.Println(msgf("setup.defaultProfile", settings.DefaultProfile))
 	fmt.Println()
 
 	return settings, nil
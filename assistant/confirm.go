@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ASCII文字 (英数字・記号) の1トークンあたりのおおよその文字数
+// 日本語などASCII以外の文字は1文字を1トークンとして見積もる
+const asciiCharsPerToken = 4
+
+// 入力テキストのトークン数をAPIを呼び出さずに大まかに見積もる
+func estimateTokens(text string) int {
+	var ascii, others int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			others++
+		}
+	}
+	return others + (ascii+asciiCharsPerToken-1)/asciiCharsPerToken
+}
+
+// 入力テキストのおおよその大きさを表示し、送信してよいかをy/Nで確認する
+// y (yes) 以外の回答や入力の終端 (EOF) の場合はfalseを返す
+func confirmLargeInput(in io.Reader, out io.Writer, text string) (bool, error) {
+	fmt.Fprint(out, msgf("confirm.largeInput", utf8.RuneCountInString(text), estimateTokens(text)))
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		fmt.Fprintln(out)
+		return false, scanner.Err()
+	}
+	answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return answer == "y" || answer == "yes", nil
+}
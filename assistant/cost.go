@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// モデル名のプレフィックスと入力100万トークンあたりの料金 (USD、プロンプトが20万トークン以下の場合の標準料金)
+// 前方一致で先に見つかったものを使うため、より具体的なプレフィックスを先に書く
+// 料金は改定されることがあるため、見積もりの目安としてのみ使う
+var modelInputPrices = []struct {
+	Prefix        string
+	USDPerMillion float64
+}{
+	{Prefix: "gemini-3-pro", USDPerMillion: 2.00},
+	{Prefix: "gemini-3-flash", USDPerMillion: 0.50},
+	{Prefix: "gemini-2.5-pro", USDPerMillion: 1.25},
+	{Prefix: "gemini-2.5-flash-lite", USDPerMillion: 0.10},
+	{Prefix: "gemini-2.5-flash", USDPerMillion: 0.30},
+	{Prefix: "gemini-2.0-flash-lite", USDPerMillion: 0.075},
+	{Prefix: "gemini-2.0-flash", USDPerMillion: 0.10},
+}
+
+// 入力トークン数から入力の料金 (USD) を見積もる
+// 料金が不明なモデルの場合はfalseを返す
+func estimateInputCost(modelName string, tokens int32) (float64, bool) {
+	name := strings.ToLower(strings.TrimSpace(modelName))
+	name = strings.TrimPrefix(name, "models/")
+	for _, entry := range modelInputPrices {
+		if strings.HasPrefix(name, entry.Prefix) {
+			return float64(tokens) / 1_000_000 * entry.USDPerMillion, true
+		}
+	}
+	return 0, false
+}
+
+// システム指示を含めたプロンプト全体のトークン数をカウントする
+// Gemini APIはCountTokensでシステム指示の指定に対応していないため、Vertex AI以外ではシステム指示を入力の先頭に加えてカウントする
+func countPromptTokens(ctx context.Context, counter tokenCounter, llmReqConfig LlmRequestConfig, vertexAI bool) (int32, error) {
+	contents := buildContents(llmReqConfig)
+	var config *genai.CountTokensConfig
+	if vertexAI {
+		config = &genai.CountTokensConfig{SystemInstruction: genai.NewContentFromText(llmReqConfig.SystemInstruction, genai.RoleUser)}
+	} else {
+		contents = append(genai.Text(llmReqConfig.SystemInstruction), contents...)
+	}
+	resp, err := counter.CountTokens(ctx, llmReqConfig.Model, contents, config)
+	if err != nil {
+		return 0, errorf("err.countTokens", err)
+	}
+	return resp.TotalTokens, nil
+}
+
+// プロンプトのトークン数と入力の料金の見積もりを出力する (-count-only)
+func printTokenCount(w io.Writer, modelName string, tokens int32) {
+	fmt.Fprintln(w, "Prompt token count:   ", tokens)
+	cost, ok := estimateInputCost(modelName, tokens)
+	if !ok {
+		fmt.Fprintf(w, "Estimated input cost:  (unknown price for model '%s')\n", modelName)
+		return
+	}
+	fmt.Fprintf(w, "Estimated input cost:  $%.6f (%s)\n", cost, modelName)
+}
@@ -0,0 +1,87 @@
+package main
+
+import "unicode"
+
+// 入力テキストを日本語とみなす、文字全体に占める日本語文字の割合の閾値
+const japaneseRatioThreshold = 0.3
+
+// 文字 (記号・空白・数字を除く) に占める日本語文字 (ひらがな・カタカナ・漢字) の割合を返す
+func japaneseRatio(text string) float64 {
+	var letters, japanese int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han) || r == 'ー' {
+			japanese++
+		}
+	}
+	if letters == 0 {
+		return 0
+	}
+	return float64(japanese) / float64(letters)
+}
+
+// 入力テキストが主に日本語で書かれているかを判定する
+func isPredominantlyJapanese(text string) bool {
+	return japaneseRatio(text) >= japaneseRatioThreshold
+}
+
+// 文字種から判定する言語と、その言語に属する文字の集合
+// 仮名は日本語にのみ現れるため、漢字は仮名の有無で日本語と中国語を区別する
+var scriptLanguages = []struct {
+	Lang   string
+	Tables []*unicode.RangeTable
+}{
+	{"ko", []*unicode.RangeTable{unicode.Hangul}},
+	{"ru", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"el", []*unicode.RangeTable{unicode.Greek}},
+	{"ar", []*unicode.RangeTable{unicode.Arabic}},
+	{"he", []*unicode.RangeTable{unicode.Hebrew}},
+	{"th", []*unicode.RangeTable{unicode.Thai}},
+	{"hi", []*unicode.RangeTable{unicode.Devanagari}},
+	// ラテン文字の言語は区別できないため、まとめて英語として扱う
+	{"en", []*unicode.RangeTable{unicode.Latin}},
+}
+
+// 入力テキストの言語を文字種 (Unicodeのスクリプト) から判定し、言語コードと確信度 (0〜1) を返す
+// 確信度は文字 (記号・空白・数字を除く) に占める、判定した言語の文字の割合
+// 文字が含まれない場合は "und" と確信度0を返す
+func detectLanguage(text string) (string, float64) {
+	var letters, kana, han int
+	counts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana) || r == 'ー':
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		default:
+			for i, sl := range scriptLanguages {
+				if unicode.In(r, sl.Tables...) {
+					counts[i]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return "und", 0
+	}
+
+	lang, best := "zh", han
+	if kana > 0 {
+		lang, best = "ja", kana+han
+	}
+	for i, count := range counts {
+		if count > best {
+			lang, best = scriptLanguages[i].Lang, count
+		}
+	}
+	return lang, float64(best) / float64(letters)
+}
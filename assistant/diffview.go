@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+	"golang.org/x/text/width"
+)
+
+// 2列で表示する端末の幅の最小値 (これより狭い場合は原文と訳文を順に表示する)
+const minSideBySideWidth = 80
+
+// 端末の幅が取得できない場合に使う幅
+const defaultTerminalWidth = 120
+
+// 段落の区切り (空行)
+var paragraphSeparator = regexp.MustCompile(`\n[ \t]*\n`)
+
+// テキストを空行で段落に分割する
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	for _, p := range paragraphSeparator.Split(strings.TrimSpace(text), -1) {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
+
+// 端末の幅を返す
+// 標準出力が端末の場合はその幅を使い、端末でない場合は環境変数COLUMNS、どちらもない場合はdefaultTerminalWidthを使う
+func terminalWidth() int {
+	if columns, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && columns > 0 {
+		return columns
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultTerminalWidth
+}
+
+// 文字の表示幅を返す (全角文字は2、それ以外は1)
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// 文字列の表示幅を返す
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// テキストを表示幅maxWidth以内の行に折り返す (単語の区切りは考慮しない)
+func wrapText(text string, maxWidth int) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		var current strings.Builder
+		currentWidth := 0
+		for _, r := range line {
+			if r == '\t' {
+				r = ' '
+			}
+			w := runeWidth(r)
+			if currentWidth+w > maxWidth && currentWidth > 0 {
+				lines = append(lines, current.String())
+				current.Reset()
+				currentWidth = 0
+			}
+			current.WriteRune(r)
+			currentWidth += w
+		}
+		lines = append(lines, current.String())
+	}
+	return lines
+}
+
+// 原文と訳文を段落ごとに対応させて表示する
+// 端末の幅が十分な場合は2列で、狭い場合は段落ごとに原文と訳文を順に表示する
+// 段落の対応は空行で区切った順番によるもので、段落の数が異なる場合はずれることがある
+func printDiffView(w io.Writer, original, translated string, totalWidth int) {
+	originals := splitParagraphs(original)
+	translations := splitParagraphs(translated)
+	count := max(len(originals), len(translations))
+	at := func(paragraphs []string, i int) string {
+		if i < len(paragraphs) {
+			return paragraphs[i]
+		}
+		return ""
+	}
+
+	if totalWidth < minSideBySideWidth {
+		for i := range count {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "---- [%d] %s ----\n%s\n", i+1, msg("diff.original"), at(originals, i))
+			fmt.Fprintf(w, "---- [%d] %s ----\n%s\n", i+1, msg("diff.translation"), at(translations, i))
+		}
+		return
+	}
+
+	columnWidth := (totalWidth - 3) / 2
+	printRow := func(left, right string) {
+		padding := strings.Repeat(" ", max(columnWidth-displayWidth(left), 0))
+		fmt.Fprintf(w, "%s%s │ %s\n", left, padding, right)
+	}
+	printRow(msg("diff.original"), msg("diff.translation"))
+	fmt.Fprintf(w, "%s─┼─%s\n", strings.Repeat("─", columnWidth), strings.Repeat("─", columnWidth))
+	for i := range count {
+		if i > 0 {
+			printRow("", "")
+		}
+		leftLines := wrapText(at(originals, i), columnWidth)
+		rightLines := wrapText(at(translations, i), columnWidth)
+		for j := range max(len(leftLines), len(rightLines)) {
+			printRow(at(leftLines, j), at(rightLines, j))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrintDiffViewNarrowFallsBackToSequential(t *testing.T) {
+	var out strings.Builder
+	printDiffView(&out, "最初の段落\n\n二番目の段落", "First paragraph.\n\nSecond paragraph.", minSideBySideWidth-1)
+
+	want := "---- [1] 原文 ----\n最初の段落\n---- [1] 訳文 ----\nFirst paragraph.\n\n" +
+		"---- [2] 原文 ----\n二番目の段落\n---- [2] 訳文 ----\nSecond paragraph.\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestPrintDiffViewSideBySide(t *testing.T) {
+	var out strings.Builder
+	printDiffView(&out, "最初の段落\n\n二番目の段落", "First paragraph.\n\nSecond paragraph.", minSideBySideWidth)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	// 見出し、区切り線、段落1、空行、段落2
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), out.String())
+	}
+	for i, line := range lines {
+		if i != 1 && !strings.Contains(line, " │ ") {
+			t.Errorf("line %d is not in two columns: %q", i, line)
+		}
+		if got := displayWidth(line); got > minSideBySideWidth {
+			t.Errorf("line %d is %d columns wide, want at most %d: %q", i, got, minSideBySideWidth, line)
+		}
+	}
+	if !strings.HasPrefix(lines[2], "最初の段落") || !strings.HasSuffix(lines[2], "First paragraph.") {
+		t.Errorf("first paragraph row = %q", lines[2])
+	}
+}
+
+func TestTerminalWidthFallsBackToColumns(t *testing.T) {
+	if isTerminal(os.Stdout) {
+		t.Skip("標準出力が端末の場合は端末の幅が使われる")
+	}
+
+	// 狭い端末の幅がCOLUMNSで渡された場合は2列で表示しない
+	t.Setenv("COLUMNS", "60")
+	if got := terminalWidth(); got != 60 {
+		t.Errorf("terminalWidth() with COLUMNS=60 = %d, want 60", got)
+	}
+	t.Setenv("COLUMNS", "not a number")
+	if got := terminalWidth(); got != defaultTerminalWidth {
+		t.Errorf("terminalWidth() with invalid COLUMNS = %d, want %d", got, defaultTerminalWidth)
+	}
+}
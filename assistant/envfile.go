@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// -env-fileを指定しない場合に読み込む、カレントディレクトリの環境変数ファイル
+const defaultEnvFile = ".env"
+
+// 環境変数ファイル (KEY=VALUE の各行) を読み込み、環境変数に設定する
+// 既に設定されている環境変数は上書きしない
+// 空行と # で始まる行は無視し、行頭の export や値を囲む引用符 (" または ') は取り除く
+// KEY=VALUE の形式でない行は警告を表示してスキップする
+// ファイルが存在しない場合はfalseを返す
+func loadEnvFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errorf("err.openEnvFile", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.ContainsAny(key, " \t") {
+			printNotice(msgf("warn.envFileInvalidLine", path, lineNumber))
+			continue
+		}
+		value = unquoteEnvValue(strings.TrimSpace(value))
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return true, errorf("err.setEnv", key, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, errorf("err.readEnvFile", path, err)
+	}
+	return true, nil
+}
+
+// 値を囲む引用符を取り除く
+// 引用符で囲まれていない値は、空白に続く # 以降をコメントとして取り除く
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// -events で出力するイベントの種類
+const (
+	eventStart    = "start"    // リクエストの開始
+	eventChunk    = "chunk"    // 結果のテキストの差分
+	eventThought  = "thought"  // 思考プロセスのテキストの差分
+	eventMetadata = "metadata" // トークン数などのメタデータ
+	eventRetry    = "retry"    // 一時的なエラーによる再試行、またはAPIキーの切り替え
+	eventWarning  = "warning"  // 警告や進捗のメッセージ
+	eventDone     = "done"     // 正常終了
+	eventError    = "error"    // エラーによる終了
+)
+
+// -events で1行のJSONとして出力するイベント
+type progressEvent struct {
+	Type      string       `json:"type"`
+	Task      string       `json:"task,omitempty"`
+	Model     string       `json:"model,omitempty"`
+	APIMethod string       `json:"apiMethod,omitempty"`
+	Delta     string       `json:"delta,omitempty"`
+	Metadata  *statsRecord `json:"metadata,omitempty"`
+	Message   string       `json:"message,omitempty"`
+	Attempt   int          `json:"attempt,omitempty"`
+	Retries   int          `json:"retries,omitempty"`
+	DelayMs   int64        `json:"delayMs,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	ExitCode  int          `json:"exitCode,omitempty"`
+}
+
+// イベントの出力先
+// メソッドはnilでも呼び出せ、その場合は何も出力しない
+type eventEmitter struct {
+	w  io.Writer
+	mu sync.Mutex // 結果と思考プロセスのイベントが混ざらないよう、1行ずつ書き込む
+}
+
+// 進捗イベント (nilの場合は出力しない)
+var events *eventEmitter
+
+// wにイベントを出力するeventEmitterを作成する
+func newEventEmitter(w io.Writer) *eventEmitter {
+	return &eventEmitter{w: w}
+}
+
+// イベントを1行のJSONとして書き込む
+func (e *eventEmitter) emit(event progressEvent) {
+	if e == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(line, '\n'))
+}
+
+// リクエストの開始を出力する
+func (e *eventEmitter) start(taskName string, modelName string, apiMethod string) {
+	e.emit(progressEvent{Type: eventStart, Task: taskName, Model: modelName, APIMethod: apiMethod})
+}
+
+// メタデータを出力する (項目は -stats-json の記録と同じ)
+func (e *eventEmitter) metadata(record statsRecord) {
+	e.emit(progressEvent{Type: eventMetadata, Metadata: &record})
+}
+
+// 正常終了を出力する
+func (e *eventEmitter) done() {
+	e.emit(progressEvent{Type: eventDone})
+}
+
+// エラーによる終了を、プロセスの終了コードとともに出力する
+func (e *eventEmitter) fail(err error, exitCode int) {
+	e.emit(progressEvent{Type: eventError, Error: err.Error(), ExitCode: exitCode})
+}
+
+// 再試行を出力する (APIキーを切り替えた場合は待機せずに再試行するため、delayは0)
+func (e *eventEmitter) retry(message string, attempt int, retries int, delay time.Duration) {
+	e.emit(progressEvent{Type: eventRetry, Message: message, Attempt: attempt, Retries: retries, DelayMs: delay.Milliseconds()})
+}
+
+// 警告や進捗のメッセージを出力する
+func (e *eventEmitter) warning(message string) {
+	e.emit(progressEvent{Type: eventWarning, Message: message})
+}
+
+// 警告や進捗のメッセージを標準エラー出力に表示する
+// -eventsの場合は標準エラー出力をJSONの行のみにするため、warningイベントとして出力する
+func printNotice(message string) {
+	if events != nil {
+		events.warning(message)
+		return
+	}
+	fmt.Fprintln(os.Stderr, message)
+}
+
+// 再試行のメッセージを標準エラー出力に表示する (-eventsの場合はretryイベントとして出力する)
+func printRetry(message string, attempt int, retries int, delay time.Duration) {
+	if events != nil {
+		events.retry(message, attempt, retries, delay)
+		return
+	}
+	fmt.Fprintln(os.Stderr, message)
+}
+
+// エラーを標準エラー出力に表示し、終了コードcodeで終了する (-eventsの場合はerrorイベントとして出力する)
+func exitWithError(err error, code int) {
+	if events != nil {
+		events.fail(err, code)
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
+// 書き込まれたテキストを差分のイベント (chunk または thought) として出力するio.Writer
+type eventDeltaWriter struct {
+	events    *eventEmitter
+	eventType string
+}
+
+func (w eventDeltaWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.events.emit(progressEvent{Type: w.eventType, Delta: string(p)})
+	}
+	return len(p), nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// サブプロセスでmainを実行する場合に設定する環境変数
+const runMainEnvVar = "LLM_ASSISTANT_TEST_RUN_MAIN"
+
+// -eventsでは、再試行や途中で終了した出力も含めて標準エラー出力のすべての行がJSONになる
+func TestEventsStderrIsNDJSON(t *testing.T) {
+	if os.Getenv(runMainEnvVar) == "1" {
+		// "--" 以降の引数でmainを実行する
+		i := slices.Index(os.Args, "--")
+		os.Args = append([]string{os.Args[0]}, os.Args[i+1:]...)
+		main()
+		os.Exit(exitOK)
+	}
+
+	// 1回目は一時的なエラー (503)、2回目は最大出力トークン数で途中まで出力して終了する
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"途中まで\"}]}}]}\n\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"の出力\"}]},\"finishReason\":\"MAX_TOKENS\"}]}\n\n")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	settingsPath := filepath.Join(dir, "settings.json")
+	settings := fmt.Sprintf(`{"defaultProfile":"test","profiles":{"test":{"apiMethod":"apiKey","apiKeyConfig":{"apiKeyEnvVarName":"LLM_ASSISTANT_TEST_API_KEY"},"baseUrl":%q}}}`, server.URL)
+	if err := os.WriteFile(settingsPath, []byte(settings), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestEventsStderrIsNDJSON$", "--",
+		"-config", settingsPath, "-task", "translate", "-model", "gemini-2.5-flash", "-retries", "1", "-events-only", "テスト")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), runMainEnvVar+"=1", "LLM_ASSISTANT_TEST_API_KEY=test",
+		"HOME="+dir, "XDG_CONFIG_HOME="+dir, "XDG_CACHE_HOME="+dir, "XDG_STATE_HOME="+dir)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitIncomplete {
+		t.Fatalf("exit = %v, want exit code %d\nstderr:\n%s", err, exitIncomplete, stderr.String())
+	}
+
+	var types []string
+	var last progressEvent
+	for _, line := range strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n") {
+		var event progressEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Errorf("stderr line is not JSON: %q", line)
+			continue
+		}
+		types = append(types, event.Type)
+		last = event
+	}
+	for _, want := range []string{eventStart, eventRetry, eventChunk, eventError} {
+		if !slices.Contains(types, want) {
+			t.Errorf("events %v do not contain %q", types, want)
+		}
+	}
+	if last.Type != eventError || last.ExitCode != exitIncomplete {
+		t.Errorf("last event = %+v, want error with exitCode %d", last, exitIncomplete)
+	}
+}
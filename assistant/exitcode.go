@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/genai"
+)
+
+// 終了コード
+// スクリプトから失敗の種類を判別できるよう、失敗の分類ごとに異なる値を返す
+const (
+	exitOK            = 0   // 成功
+	exitGeneral       = 1   // その他のエラー
+	exitUsage         = 2   // コマンドライン引数や設定ファイルの誤り
+	exitAuth          = 3   // 認証情報の不足や認証・認可の失敗
+	exitModelNotFound = 4   // 指定したモデルが見つからない
+	exitTimeout       = 5   // タイムアウト
+	exitBlocked       = 6   // 安全性フィルタなどによるコンテンツのブロック
+	exitIncomplete    = 7   // 出力の途中でエラーが発生した (途中までの出力は不完全)
+	exitInterrupted   = 130 // Ctrl-C (SIGINT) による中断
+)
+
+// 指定したモデルが見つからないか、generateContentをサポートしていないことを表すエラー
+type modelNotFoundError struct {
+	Model string
+	Err   error
+}
+
+func (e *modelNotFoundError) Error() string {
+	if e.Err != nil {
+		return msgf("err.modelNotFoundCause", e.Model, e.Err)
+	}
+	return msgf("err.modelNotFound", e.Model)
+}
+
+func (e *modelNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// APIエラーのHTTPステータスが404 (NOT_FOUND) かを判定する
+func isNotFoundError(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == 404 || apiErr.Status == "NOT_FOUND"
+}
+
+// 安全性フィルタなどにより応答がブロックされたことを表すエラー
+type contentBlockedError struct {
+	Reason string
+}
+
+func (e *contentBlockedError) Error() string {
+	return msgf("err.contentBlocked", e.Reason)
+}
+
+// 出力の途中でエラーが発生し、途中までの出力が不完全であることを表すエラー
+type incompleteOutputError struct {
+	Err error
+}
+
+func (e *incompleteOutputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *incompleteOutputError) Unwrap() error {
+	return e.Err
+}
+
+// エラーの種類に応じた終了コードを返す
+func exitCodeForError(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var incomplete *incompleteOutputError
+	if errors.As(err, &incomplete) {
+		return exitIncomplete
+	}
+	var notFound *modelNotFoundError
+	if errors.As(err, &notFound) {
+		return exitModelNotFound
+	}
+	var blocked *contentBlockedError
+	if errors.As(err, &blocked) {
+		return exitBlocked
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return exitTimeout
+	}
+
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case 401, 403:
+			return exitAuth
+		case 404:
+			return exitModelNotFound
+		case 408, 504:
+			return exitTimeout
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return exitTimeout
+	}
+
+	return exitGeneral
+}
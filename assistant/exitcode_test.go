@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestIsNotFoundError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"code 404", genai.APIError{Code: 404}, true},
+		{"status NOT_FOUND", genai.APIError{Status: "NOT_FOUND"}, true},
+		{"wrapped 404", fmt.Errorf("API呼び出し中にエラーが発生しました: %w", genai.APIError{Code: 404}), true},
+		{"429", genai.APIError{Code: 429, Status: "RESOURCE_EXHAUSTED"}, false},
+		{"503", genai.APIError{Code: 503, Status: "UNAVAILABLE"}, false},
+		// メッセージに "404" を含むだけのエラーは対象外
+		{"untyped", errors.New("unexpected status 404"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFoundError(tt.err); got != tt.want {
+				t.Errorf("isNotFoundError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCodeForError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, exitOK},
+		{"model not found", &modelNotFoundError{Model: "test-model"}, exitModelNotFound},
+		{"wrapped model not found", fmt.Errorf("stage 1: %w", &modelNotFoundError{Model: "test-model", Err: genai.APIError{Code: 404}}), exitModelNotFound},
+		{"api 404", genai.APIError{Code: 404}, exitModelNotFound},
+		{"blocked", &contentBlockedError{Reason: "SAFETY"}, exitBlocked},
+		{"incomplete", &incompleteOutputError{Err: errors.New("cut off")}, exitIncomplete},
+		{"api 401", genai.APIError{Code: 401}, exitAuth},
+		{"api 403", genai.APIError{Code: 403}, exitAuth},
+		{"api 504", genai.APIError{Code: 504}, exitTimeout},
+		{"deadline", context.DeadlineExceeded, exitTimeout},
+		{"api 500", genai.APIError{Code: 500}, exitGeneral},
+		{"other", errors.New("something went wrong"), exitGeneral},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeForError(tt.err); got != tt.want {
+				t.Errorf("exitCodeForError(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 用語集の1項目 (略語と、その正式名称や説明)
+type glossaryEntry struct {
+	Term       string
+	Definition string
+}
+
+// 用語集ファイルを読み込む
+// 各行は "略語: 正式名称や説明" の形式で、空行と # で始まる行は無視する
+func loadGlossary(path string) ([]glossaryEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errorf("err.readGlossary", err)
+	}
+	defer file.Close()
+
+	var entries []glossaryEntry
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		term, definition, found := strings.Cut(line, ":")
+		term, definition = strings.TrimSpace(term), strings.TrimSpace(definition)
+		if !found || term == "" || definition == "" {
+			return nil, errorf("err.parseGlossaryLine", path, lineNumber, line)
+		}
+		entries = append(entries, glossaryEntry{Term: term, Definition: definition})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errorf("err.readGlossary", err)
+	}
+	if len(entries) == 0 {
+		return nil, errorf("err.emptyGlossary", path)
+	}
+	return entries, nil
+}
+
+// 用語集をシステム指示に埋め込む形式 ("- 略語: 説明" の各行) に整形する
+func formatGlossary(entries []glossaryEntry) string {
+	var builder strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&builder, "- %s: %s\n", entry.Term, entry.Definition)
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
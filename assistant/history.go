@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// -historyで表示する件数のデフォルト値
+const defaultHistoryLimit = 20
+
+// 履歴の一覧で表示する入力・出力の最大文字数
+const historyPreviewLength = 60
+
+// 履歴ファイルに記録する1回の実行の記録
+type historyEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Task      string    `json:"task"`
+	Model     string    `json:"model"`
+	Input     string    `json:"input"`
+	Output    string    `json:"output"`
+}
+
+// 履歴ファイルのパスを返す (設定ファイルと同じディレクトリに置く)
+func getHistoryPath() (string, error) {
+	settingsPath, err := getSettingsPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(settingsPath), "history.jsonl"), nil
+}
+
+// 実行の記録を履歴ファイルに1行のJSONとして追記する
+func appendHistory(entry historyEntry) error {
+	historyPath, err := getHistoryPath()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errorf("err.serializeHistory", err)
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		return errorf("err.createHistoryDir", err)
+	}
+	// 入力や出力には機密情報が含まれることがあるため、本人のみ読み書きできるようにする
+	f, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errorf("err.openHistory", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return errorf("err.writeHistory", err)
+	}
+	return nil
+}
+
+// 履歴ファイルを読み込む (古い順)
+// 解析できない行は読み飛ばす
+func readHistory(r io.Reader) ([]historyEntry, error) {
+	var entries []historyEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, errorf("err.readHistory", err)
+	}
+	return entries, nil
+}
+
+// 改行をまとめて1行にし、最大文字数で切り詰める
+func previewText(text string, maxLength int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength]) + "…"
+}
+
+// 入力または出力に検索語 (大文字小文字を区別しない) を含む履歴だけを返す
+func searchHistory(entries []historyEntry, term string) []historyEntry {
+	term = strings.ToLower(term)
+	var matched []historyEntry
+	for _, entry := range entries {
+		if strings.Contains(strings.ToLower(entry.Input), term) || strings.Contains(strings.ToLower(entry.Output), term) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// 履歴の新しいものから最大limit件を表示する
+func printHistory(w io.Writer, entries []historyEntry, limit int) {
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Fprintf(w, "%s [%s] %s\n", entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Task, entry.Model)
+		fmt.Fprintf(w, "    %s: %s\n", msg("history.input"), previewText(entry.Input, historyPreviewLength))
+		fmt.Fprintf(w, "    %s: %s\n", msg("history.output"), previewText(entry.Output, historyPreviewLength))
+	}
+}
+
+// -historyの処理を実行し、終了コードを返す
+func runHistory(limit int, search string) int {
+	historyPath, err := getHistoryPath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitGeneral
+	}
+	f, err := os.Open(historyPath)
+	if os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, msgf("status.noHistory", historyPath))
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, msgf("warn.openHistory", err))
+		return exitGeneral
+	}
+	defer f.Close()
+
+	entries, err := readHistory(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitGeneral
+	}
+	if search != "" {
+		entries = searchHistory(entries, search)
+	}
+	printHistory(os.Stdout, entries, limit)
+	return exitOK
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 入力として添付する画像
+type imageInput struct {
+	Path     string
+	MIMEType string
+	Data     []byte
+}
+
+// 対応している画像の拡張子とMIMEタイプ
+var imageMIMETypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+	".heic": "image/heic",
+	".heif": "image/heif",
+}
+
+// 画像ファイルを読み込み、拡張子からMIMEタイプを判定する
+func loadImage(path string) (*imageInput, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	mimeType, ok := imageMIMETypes[ext]
+	if !ok {
+		return nil, errorf("err.unsupportedImage", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorf("err.readImage", err)
+	}
+
+	return &imageInput{
+		Path:     path,
+		MIMEType: mimeType,
+		Data:     data,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// 複数の入力ファイルを連結する際の区切り
+const inputFileSeparator = "\n\n---\n\n"
+
+// 複数回指定できる文字列のフラグ (指定された順序を保持する)
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// 入力ファイルを指定された順に読み込み、区切りを挟んで連結する
+// 送信前にすべてのファイルが存在することを確認し、1つでも読めない場合はエラーを返す
+// encが指定されている場合は各ファイルをその文字コードからUTF-8に変換する
+func readInputFiles(paths []string, enc encoding.Encoding) (string, error) {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", errorf("err.inputFileNotFound", path, err)
+		}
+		if info.IsDir() {
+			return "", errorf("err.inputFileIsDir", path)
+		}
+	}
+
+	contents := make([]string, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", errorf("err.readInputFile", path, err)
+		}
+		data, err = decodeText(data, enc)
+		if err != nil {
+			return "", errorf("err.inputFile", path, err)
+		}
+		contents = append(contents, strings.TrimRight(string(data), "\n"))
+	}
+	return strings.Join(contents, inputFileSeparator), nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"google.golang.org/genai"
+)
+
+// クォータ超過 (429 / RESOURCE_EXHAUSTED) のエラーかを判定する
+func isQuotaError(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == 429 || apiErr.Status == "RESOURCE_EXHAUSTED"
+}
+
+// 書き込みがあったかを記録するio.Writer
+// 出力済みの場合は別のAPIキーでやり直せないため、その判定に使う
+type writeTracker struct {
+	w       io.Writer
+	written *bool
+}
+
+func (t writeTracker) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		*t.written = true
+	}
+	return t.w.Write(p)
+}
@@ -1,181 +1,1329 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"google.golang.org/genai"
 )
 
-// コマンドライン引数を解析し、モデル名、初期化フラグ、タスク定義、入力テキストを返す
+// コマンドラインオプション
+type cliOptions struct {
+	ModelName         string
+	ModelFallbacks    []string
+	ThinkingFlag      bool
+	ThinkingSet       bool
+	ThinkingLevel     string
+	ThinkingBudget    *int32
+	InitFlag          bool
+	ConfigPath        string
+	EnvFile           string
+	UILang            string
+	Profile           string
+	BaseURL           string
+	Backend           string
+	DryRun            bool
+	Yes               bool
+	Diff              bool
+	EnglishOnly       bool
+	ImagePath         string
+	InputFiles        stringListFlag
+	InputEncoding     encoding.Encoding // -file、-jsonlの入力の文字コード (nilの場合はUTF-8)
+	OutputEncoding    encoding.Encoding // 結果の出力の文字コード (nilの場合はUTF-8)
+	Detect            bool
+	DetectOnly        bool
+	CountOnly         bool
+	Ground            bool
+	Tone              string
+	Bullets           *bool
+	Romaji            bool
+	Candidates        int
+	Preflight         string
+	OutputPath        string
+	Force             bool
+	CharsPerStep      int
+	MillisPerChar     int
+	REPL              bool
+	Debug             bool
+	Verbose           bool
+	MetadataFormat    string
+	CheckModel        bool
+	RefreshModels     bool
+	ListModels        bool
+	History           bool
+	HistoryLimit      int
+	HistorySearch     string
+	Check             bool
+	NoSpinner         bool
+	NoStream          bool
+	ThinkColor        string
+	StatsJSONPath     string
+	MaxTotalTokens    int
+	MaxTokens         int
+	MaxDuration       time.Duration
+	Seed              *int32 // nilの場合はシードを指定しない
+	StopSequences     stringListFlag
+	Pick              string
+	NoThoughts        bool
+	Verbatim          bool
+	WrapWidth         int // 結果を折り返す表示幅 (0の場合は折り返さない)
+	StripBold         bool
+	JSONLPath         string
+	StdinStream       bool
+	Events            bool // 進捗イベントをJSONの行として標準エラー出力に出力する
+	EventsOnly        bool // -events に加え、標準出力への結果の表示を省略する
+	SchemaPath        string
+	GlossaryPath      string
+	SystemPrefix      string
+	AuditLogPath      string
+	Concurrency       int
+	Retries           *int // nilの場合は設定ファイルの値またはデフォルト値を使う
+	RequestsPerSecond float64
+	Task              TaskDefinition
+	Chain             []TaskDefinition // -chainで指定されたタスク (先頭のタスクはTaskにも設定する)
+	CompareModels     []string         // -compareで指定された比較するモデル
+	InputText         string
+	ModelFilter       string
+	ModelsLimit       int
+	Completion        string
+	CompletionFlags   []completionFlag
+}
+
+// コマンドライン引数を解析し、モデル名、初期化フラグ、タスク定義、入力テキストなどを返す
 // ただしinitがtrueの場合はテキストは不要
-func parseArgs() (modelName string, thinkingFlag bool, thinkingLevel string, initFlag bool, task TaskDefinition, inputText string, err error) {
+func parseArgs() (opts cliOptions, err error) {
 	defaultTask, _ := getTaskDefinition("translate")
+	opts.Task = defaultTask
+
+	// ヘルプの文言に使うため、フラグの定義より前にメッセージの言語を決める
+	uiLang = detectUILang(os.Args[1:])
 
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	flagSet.SetOutput(flag.CommandLine.Output())
-	flagSet.StringVar(&modelName, "model", "gemini-3-flash-preview", "モデル名を指定します")
+	flagSet.StringVar(&opts.ModelName, "model", "gemini-3-flash-preview", msg("flag.model"))
+	var modelFallbacks string
+	var inputEncoding, outputEncoding string
+	flagSet.StringVar(&inputEncoding, "input-encoding", "utf-8", msg("flag.input-encoding"))
+	flagSet.StringVar(&outputEncoding, "output-encoding", "utf-8", msg("flag.output-encoding"))
+	flagSet.StringVar(&modelFallbacks, "model-fallback", "", msg("flag.model-fallback"))
 	var taskName string
-	flagSet.StringVar(&taskName, "task", "", "タスク名を指定します (必須)")
-	flagSet.BoolVar(&thinkingFlag, "think", false, "思考プロセスを有効にします")
-	flagSet.StringVar(&thinkingLevel, "think-level", "", "Gemini 3向けの思考レベルを指定します (minimal|low|medium|high)")
-	flagSet.BoolVar(&initFlag, "init", false, "対話形式で設定を初期化します")
+	flagSet.StringVar(&taskName, "task", "", msg("flag.task"))
+	var chain string
+	flagSet.StringVar(&chain, "chain", "", msg("flag.chain"))
+	var compare string
+	flagSet.StringVar(&compare, "compare", "", msg("flag.compare"))
+	flagSet.BoolVar(&opts.ThinkingFlag, "think", false, msg("flag.think"))
+	flagSet.StringVar(&opts.ThinkingLevel, "think-level", "", msg("flag.think-level"))
+	var thinkingBudget int
+	flagSet.IntVar(&thinkingBudget, "think-budget", 0, msg("flag.think-budget"))
+	flagSet.BoolVar(&opts.InitFlag, "init", false, msg("flag.init"))
+	flagSet.StringVar(&opts.Profile, "profile", "", msg("flag.profile"))
+	flagSet.BoolVar(&opts.Diff, "diff", false, msg("flag.diff"))
+	flagSet.BoolVar(&opts.EnglishOnly, "english-only", false, msg("flag.english-only"))
+	flagSet.BoolVar(&opts.DryRun, "dry-run", false, msg("flag.dry-run"))
+	flagSet.Var(&opts.InputFiles, "file", msg("flag.file"))
+	flagSet.StringVar(&opts.ImagePath, "image", "", msg("flag.image"))
+	flagSet.BoolVar(&opts.Detect, "detect", false, msg("flag.detect"))
+	flagSet.BoolVar(&opts.DetectOnly, "detect-only", false, msg("flag.detect-only"))
+	flagSet.BoolVar(&opts.CountOnly, "count-only", false, msg("flag.count-only"))
+	flagSet.BoolVar(&opts.Ground, "ground", false, msg("flag.ground"))
+	flagSet.StringVar(&opts.Tone, "tone", "", msg("flag.tone"))
+	var bullets bool
+	flagSet.BoolVar(&bullets, "bullets", true, msg("flag.bullets"))
+	flagSet.IntVar(&opts.Candidates, "candidates", 1, msg("flag.candidates"))
+	flagSet.BoolVar(&opts.Romaji, "romaji", false, msg("flag.romaji"))
+	flagSet.StringVar(&opts.Preflight, "preflight", "", msg("flag.preflight"))
+	flagSet.StringVar(&opts.OutputPath, "output", "", msg("flag.output"))
+	flagSet.BoolVar(&opts.Force, "force", false, msg("flag.force"))
+	flagSet.IntVar(&opts.CharsPerStep, "chars-per-step", 0, msg("flag.chars-per-step"))
+	flagSet.IntVar(&opts.MillisPerChar, "millis-per-char", 0, msg("flag.millis-per-char"))
+	flagSet.BoolVar(&opts.REPL, "repl", false, msg("flag.repl"))
+	flagSet.BoolVar(&opts.Debug, "debug", false, msg("flag.debug"))
+	flagSet.BoolVar(&opts.Verbose, "verbose", false, msg("flag.verbose"))
+	flagSet.StringVar(&opts.BaseURL, "base-url", "", msg("flag.base-url"))
+	flagSet.StringVar(&opts.AuditLogPath, "audit-log", "", msg("flag.audit-log"))
+	flagSet.StringVar(&opts.Backend, "backend", "", msg("flag.backend"))
+	flagSet.BoolVar(&opts.CheckModel, "check-model", false, msg("flag.check-model"))
+	flagSet.BoolVar(&opts.NoStream, "no-stream", false, msg("flag.no-stream"))
+	flagSet.BoolVar(&opts.NoSpinner, "no-spinner", false, msg("flag.no-spinner"))
+	flagSet.StringVar(&opts.ThinkColor, "think-color", "", msg("flag.think-color"))
+	flagSet.StringVar(&opts.StatsJSONPath, "stats-json", "", msg("flag.stats-json"))
+	flagSet.DurationVar(&opts.MaxDuration, "max-duration", 0, msg("flag.max-duration"))
+	flagSet.StringVar(&opts.MetadataFormat, "metadata-format", metadataFormatTable, msg("flag.metadata-format"))
+	flagSet.IntVar(&opts.MaxTokens, "max-tokens", 0, msg("flag.max-tokens"))
+	var seed int
+	flagSet.IntVar(&seed, "seed", 0, msg("flag.seed"))
+	flagSet.Var(&opts.StopSequences, "stop", msg("flag.stop"))
+	flagSet.StringVar(&opts.Pick, "pick", "", msg("flag.pick"))
+	flagSet.IntVar(&opts.MaxTotalTokens, "max-total-tokens", 0, msg("flag.max-total-tokens"))
+	flagSet.BoolVar(&opts.Verbatim, "verbatim", false, msg("flag.verbatim"))
+	var wrap string
+	flagSet.StringVar(&wrap, "wrap", "", msg("flag.wrap"))
+	flagSet.BoolVar(&opts.StripBold, "strip-bold", false, msg("flag.strip-bold"))
+	flagSet.BoolVar(&opts.NoThoughts, "no-thoughts", false, msg("flag.no-thoughts"))
+	flagSet.StringVar(&opts.JSONLPath, "jsonl", "", msg("flag.jsonl"))
+	flagSet.BoolVar(&opts.StdinStream, "stdin-stream", false, msg("flag.stdin-stream"))
+	flagSet.BoolVar(&opts.Events, "events", false, msg("flag.events"))
+	flagSet.BoolVar(&opts.EventsOnly, "events-only", false, msg("flag.events-only"))
+	flagSet.IntVar(&opts.Concurrency, "concurrency", 4, msg("flag.concurrency"))
+	flagSet.BoolVar(&opts.Yes, "yes", false, msg("flag.yes"))
+	flagSet.BoolVar(&opts.Check, "check", false, msg("flag.check"))
+	flagSet.BoolVar(&opts.History, "history", false, msg("flag.history"))
+	flagSet.IntVar(&opts.HistoryLimit, "history-limit", defaultHistoryLimit, msg("flag.history-limit"))
+	flagSet.StringVar(&opts.HistorySearch, "history-search", "", msg("flag.history-search"))
+	flagSet.BoolVar(&opts.ListModels, "list-models", false, msg("flag.list-models"))
+	flagSet.IntVar(&opts.ModelsLimit, "models-limit", 0, msg("flag.models-limit"))
+	flagSet.BoolVar(&opts.RefreshModels, "refresh-models", false, msg("flag.refresh-models"))
+	flagSet.StringVar(&opts.SchemaPath, "schema", "", msg("flag.schema"))
+	flagSet.StringVar(&opts.UILang, "lang", "", msg("flag.lang"))
+	var retries int
+	flagSet.IntVar(&retries, "retries", defaultRetries, msg("flag.retries"))
+	flagSet.Float64Var(&opts.RequestsPerSecond, "rps", 0, msg("flag.rps"))
+	flagSet.StringVar(&opts.Completion, "completion", "", msg("flag.completion"))
+	flagSet.StringVar(&opts.GlossaryPath, "glossary", "", msg("flag.glossary"))
+	flagSet.StringVar(&opts.SystemPrefix, "system-prefix", "", msg("flag.system-prefix"))
+	flagSet.StringVar(&opts.ConfigPath, "config", "", msg("flag.config"))
+	flagSet.StringVar(&opts.EnvFile, "env-file", "", msg("flag.env-file"))
 
 	// カスタムUsage関数を設定（タスク指定ルールを追加）
 	flagSet.Usage = func() {
-		fmt.Fprintf(flagSet.Output(), "Usage: %s [options] <入力テキスト>\n\n", os.Args[0])
-		fmt.Fprintf(flagSet.Output(), "Example: %s --task translate \"翻訳したいテキスト\"\n\n", os.Args[0])
-		fmt.Fprintf(flagSet.Output(), "Init only: %s -init\n\n", os.Args[0])
-		fmt.Fprintf(flagSet.Output(), "Options:\n")
+		fmt.Fprintf(flagSet.Output(), msg("usage.usage")+"\n\n", os.Args[0])
+		fmt.Fprintf(flagSet.Output(), msg("usage.example")+"\n\n", os.Args[0])
+		fmt.Fprintf(flagSet.Output(), msg("usage.init")+"\n\n", os.Args[0])
+		fmt.Fprintf(flagSet.Output(), msg("usage.listModels")+"\n\n", os.Args[0])
+		fmt.Fprintf(flagSet.Output(), msg("usage.check")+"\n\n", os.Args[0])
+		fmt.Fprintln(flagSet.Output(), msg("usage.options"))
 		flagSet.PrintDefaults()
-		fmt.Fprintf(flagSet.Output(), "\nTasks:\n%s\n", taskUsageLines())
+		fmt.Fprintf(flagSet.Output(), "\n%s\n%s\n", msg("usage.tasks"), taskUsageLines())
 	}
 
 	if err := flagSet.Parse(os.Args[1:]); err != nil {
-		return "", false, "", false, defaultTask, "", err
+		return opts, err
+	}
+
+	// detectUILangと同じく、大文字小文字と前後の空白を区別しない
+	opts.UILang = strings.ToLower(strings.TrimSpace(opts.UILang))
+	if opts.UILang != "" && !isSupportedUILang(opts.UILang) {
+		err := errorf("err.invalidLang", opts.UILang)
+		fmt.Fprintln(flagSet.Output(), err)
+		return opts, err
+	}
+
+	if opts.MaxTotalTokens < 0 {
+		err := errorf("err.negativeMaxTotalTokens", opts.MaxTotalTokens)
+		fmt.Fprintln(flagSet.Output(), err)
+		return opts, err
+	}
+
+	if err := validatePreflightMode(opts.Preflight); err != nil {
+		fmt.Fprintln(flagSet.Output(), err)
+		return opts, err
 	}
 
 	// -think-level オプションが指定されていたら ThinkingFlag を立てる
-	if strings.TrimSpace(thinkingLevel) != "" {
-		thinkingFlag = true
+	if strings.TrimSpace(opts.ThinkingLevel) != "" {
+		opts.ThinkingFlag = true
+	}
+
+	// -think-budget オプションが指定されていたら ThinkingFlag を立てる
+	// また思考関連のフラグが明示的に指定されたかを記録する (未指定の場合はタスクのデフォルトを使う)
+	flagSet.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "think-budget":
+			budget := int32(thinkingBudget)
+			opts.ThinkingBudget = &budget
+			opts.ThinkingFlag = true
+			opts.ThinkingSet = true
+		case "think", "think-level":
+			opts.ThinkingSet = true
+		case "bullets":
+			opts.Bullets = &bullets
+		case "retries":
+			opts.Retries = &retries
+			if retries < 0 {
+				err = errorf("err.negativeRetries", retries)
+			}
+		case "seed":
+			if seed < math.MinInt32 || seed > math.MaxInt32 {
+				err = errorf("err.invalidSeed", math.MinInt32, math.MaxInt32, seed)
+			}
+			s := int32(seed)
+			opts.Seed = &s
+		case "max-tokens":
+			if opts.MaxTokens <= 0 {
+				err = errorf("err.invalidMaxTokens", opts.MaxTokens)
+			}
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(flagSet.Output(), err)
+		return opts, err
 	}
 
 	// -initフラグが設定されている場合は、タスクとテキストは不要
-	if initFlag {
-		return modelName, false, "", initFlag, defaultTask, "", nil
+	if opts.InitFlag {
+		opts.ThinkingFlag = false
+		opts.ThinkingLevel = ""
+		opts.ThinkingBudget = nil
+		return opts, nil
+	}
+
+	if opts.Backend != "" && opts.Backend != "apiKey" && opts.Backend != "vertexAI" {
+		flagSet.Usage()
+		return opts, errorf("err.invalidBackend", opts.Backend)
+	}
+
+	opts.InputEncoding, err = lookupTextEncoding(inputEncoding)
+	if err != nil {
+		flagSet.Usage()
+		return opts, err
+	}
+	opts.OutputEncoding, err = lookupTextEncoding(outputEncoding)
+	if err != nil {
+		flagSet.Usage()
+		return opts, err
+	}
+
+	// -model-fallbackはカンマ区切りで複数のモデルを指定できる
+	for _, model := range strings.Split(modelFallbacks, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			opts.ModelFallbacks = append(opts.ModelFallbacks, model)
+		}
+	}
+
+	// -completionフラグが設定されている場合は、タスクとテキストは不要で補完スクリプト用にフラグの一覧を保持する
+	if opts.Completion != "" {
+		flagSet.VisitAll(func(f *flag.Flag) {
+			boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+			opts.CompletionFlags = append(opts.CompletionFlags, completionFlag{
+				Name:   f.Name,
+				Usage:  f.Usage,
+				IsBool: ok && boolFlag.IsBoolFlag(),
+			})
+		})
+		return opts, nil
+	}
+
+	// -history、-history-searchフラグが設定されている場合は、タスクとテキストは不要
+	if opts.History || opts.HistorySearch != "" {
+		if opts.HistoryLimit < 1 {
+			err := errorf("err.invalidHistoryLimit", opts.HistoryLimit)
+			fmt.Fprintln(flagSet.Output(), err)
+			return opts, err
+		}
+		opts.History = true
+		return opts, nil
+	}
+
+	// -checkフラグが設定されている場合は、タスクとテキストは不要
+	if opts.Check {
+		return opts, nil
+	}
+
+	if opts.ModelsLimit < 0 {
+		err := errorf("err.negativeModelsLimit", opts.ModelsLimit)
+		fmt.Fprintln(flagSet.Output(), err)
+		return opts, err
+	}
+
+	// -list-modelsフラグが設定されている場合は、タスクは不要で引数はモデル名のフィルタとして扱う
+	if opts.ListModels {
+		opts.ModelFilter = strings.Join(flagSet.Args(), " ")
+		return opts, nil
+	}
+
+	// -detect-onlyフラグが設定されている場合は、タスクは不要で入力テキストの引数か-fileが必要
+	if opts.DetectOnly {
+		if len(opts.InputFiles) > 0 && flagSet.NArg() > 0 {
+			flagSet.Usage()
+			return opts, errorf("err.fileWithInput")
+		}
+		if len(opts.InputFiles) == 0 && flagSet.NArg() == 0 {
+			flagSet.Usage()
+			return opts, errorf("err.inputRequired")
+		}
+		opts.InputText = strings.Join(flagSet.Args(), " ")
+		return opts, nil
+	}
+
+	// -chainフラグが設定されている場合は、先頭のタスクをタスクとして扱う
+	if chain != "" {
+		if strings.TrimSpace(taskName) != "" {
+			flagSet.Usage()
+			return opts, errorf("err.chainWithTask")
+		}
+		opts.Chain, err = parseChain(chain)
+		if err != nil {
+			flagSet.Usage()
+			return opts, err
+		}
+		taskName = opts.Chain[0].Name
+	}
+
+	// タスクが指定されていない場合は設定ファイルのdefaultTaskを使う
+	if strings.TrimSpace(taskName) == "" {
+		settingsPathOverride = opts.ConfigPath
+		settings, err := loadSettings()
+		if err != nil {
+			err = errorf("err.loadSettings", err)
+			fmt.Fprintln(flagSet.Output(), err)
+			return opts, err
+		}
+		if settings != nil {
+			taskName = settings.DefaultTask
+		}
+	}
+
+	// タスクが指定されていない場合、端末から実行されていればメニューを表示して選択させる
+	// 入力テキストも指定されていない場合は、続けて入力させる
+	var menuInput string
+	if strings.TrimSpace(taskName) == "" && isTerminal(os.Stdin) {
+		needInput := flagSet.NArg() == 0 && len(opts.InputFiles) == 0 && opts.ImagePath == "" && !opts.REPL && opts.JSONLPath == "" && !opts.StdinStream
+		task, input, err := selectTaskInteractive(os.Stdin, os.Stderr, needInput)
+		if err != nil {
+			return opts, err
+		}
+		if needInput && strings.TrimSpace(input) == "" {
+			return opts, errorf("err.inputRequired")
+		}
+		taskName, menuInput = task.Name, input
 	}
 
 	if strings.TrimSpace(taskName) == "" {
 		flagSet.Usage()
-		return "", false, "", false, defaultTask, "", fmt.Errorf("タスク名を --task で指定してください")
+		return opts, errorf("err.taskRequired")
 	}
 
 	parsedTask, ok := getTaskDefinition(taskName)
 	if !ok {
 		flagSet.Usage()
-		return "", false, "", false, defaultTask, "", fmt.Errorf("無効なタスク名が指定されています (-task): %s", taskName)
+		return opts, errorf("err.invalidTask", taskName)
+	}
+	opts.Task = parsedTask
+
+	if opts.REPL && (opts.ImagePath != "" || opts.OutputPath != "") {
+		flagSet.Usage()
+		return opts, errorf("err.replConflict")
+	}
+	if opts.SchemaPath != "" && (opts.REPL || opts.JSONLPath != "") {
+		flagSet.Usage()
+		return opts, errorf("err.schemaConflict")
+	}
+	if len(opts.StopSequences) > maxStopSequences {
+		flagSet.Usage()
+		return opts, errorf("err.tooManyStops", maxStopSequences, len(opts.StopSequences))
+	}
+	if slices.Contains(opts.StopSequences, "") {
+		flagSet.Usage()
+		return opts, errorf("err.emptyStop")
+	}
+	if opts.Candidates < 1 || opts.Candidates > maxCandidates {
+		flagSet.Usage()
+		return opts, errorf("err.invalidCandidates", maxCandidates, opts.Candidates)
+	}
+	if opts.Candidates > 1 && (opts.REPL || opts.SchemaPath != "" || opts.JSONLPath != "") {
+		flagSet.Usage()
+		return opts, errorf("err.candidatesConflict")
+	}
+	if err := validatePick(opts.Pick); err != nil {
+		flagSet.Usage()
+		return opts, err
+	}
+	if opts.Pick != "" && opts.Candidates < 2 {
+		flagSet.Usage()
+		return opts, errorf("err.pickWithoutCandidates")
+	}
+	if opts.Diff && (opts.REPL || opts.JSONLPath != "" || opts.ImagePath != "" || opts.OutputPath != "" || opts.SchemaPath != "" || opts.Candidates > 1) {
+		flagSet.Usage()
+		return opts, errorf("err.diffConflict")
+	}
+	if opts.EnglishOnly && opts.Task.Name != "translate" {
+		flagSet.Usage()
+		return opts, errorf("err.englishOnlyTask", opts.Task.Name)
+	}
+	if opts.EnglishOnly && (opts.REPL || opts.JSONLPath != "" || opts.SchemaPath != "" || opts.Candidates > 1) {
+		flagSet.Usage()
+		return opts, errorf("err.englishOnlyConflict")
+	}
+	if len(opts.Chain) > 0 && (opts.REPL || opts.JSONLPath != "" || opts.ImagePath != "" || opts.OutputPath != "" || opts.SchemaPath != "" || opts.Candidates > 1 || opts.Diff || opts.EnglishOnly || opts.Detect) {
+		flagSet.Usage()
+		return opts, errorf("err.chainConflict")
+	}
+	if compare != "" {
+		opts.CompareModels, err = parseCompareModels(compare)
+		if err != nil {
+			flagSet.Usage()
+			return opts, err
+		}
+	}
+	if len(opts.CompareModels) > 0 && (opts.REPL || opts.JSONLPath != "" || opts.StdinStream || len(opts.Chain) > 0 || opts.OutputPath != "" || opts.SchemaPath != "" || opts.Candidates > 1 || opts.Diff || opts.EnglishOnly || opts.CountOnly || len(opts.ModelFallbacks) > 0 || opts.OutputEncoding != nil) {
+		flagSet.Usage()
+		return opts, errorf("err.compareConflict")
+	}
+	opts.WrapWidth, err = parseWrapWidth(wrap)
+	if err != nil {
+		flagSet.Usage()
+		return opts, err
+	}
+	if opts.WrapWidth > 0 && (opts.REPL || opts.JSONLPath != "" || opts.StdinStream || len(opts.Chain) > 0 || len(opts.CompareModels) > 0 || opts.OutputPath != "" || opts.SchemaPath != "" || opts.Diff || opts.Verbatim) {
+		flagSet.Usage()
+		return opts, errorf("err.wrapConflict")
+	}
+	if opts.StripBold && (opts.REPL || opts.JSONLPath != "" || opts.StdinStream || len(opts.Chain) > 0 || len(opts.CompareModels) > 0 || opts.SchemaPath != "" || opts.Verbatim) {
+		flagSet.Usage()
+		return opts, errorf("err.stripBoldConflict")
+	}
+	if opts.CountOnly && (opts.REPL || opts.JSONLPath != "" || opts.StdinStream || len(opts.Chain) > 0 || opts.OutputPath != "") {
+		flagSet.Usage()
+		return opts, errorf("err.countOnlyConflict")
+	}
+	if opts.EventsOnly {
+		opts.Events = true
+	}
+	if opts.Events && (opts.REPL || opts.JSONLPath != "" || opts.StdinStream || len(opts.Chain) > 0 || len(opts.CompareModels) > 0 || opts.CountOnly || opts.Verbose || opts.Debug) {
+		flagSet.Usage()
+		return opts, errorf("err.eventsConflict")
+	}
+	if opts.MetadataFormat != metadataFormatTable && opts.MetadataFormat != metadataFormatCompact {
+		flagSet.Usage()
+		return opts, errorf("err.invalidMetadataFormat", opts.MetadataFormat)
+	}
+	if opts.MaxDuration < 0 {
+		flagSet.Usage()
+		return opts, errorf("err.negativeMaxDuration", opts.MaxDuration)
+	}
+	if opts.OutputEncoding != nil && (opts.REPL || opts.JSONLPath != "" || opts.StdinStream) {
+		flagSet.Usage()
+		return opts, errorf("err.outputEncodingConflict")
+	}
+	if opts.Concurrency < 1 {
+		flagSet.Usage()
+		return opts, errorf("err.invalidConcurrency", opts.Concurrency)
+	}
+	if opts.JSONLPath != "" && (opts.REPL || opts.ImagePath != "" || opts.OutputPath != "" || len(opts.InputFiles) > 0) {
+		flagSet.Usage()
+		return opts, errorf("err.jsonlConflict")
+	}
+	if opts.StdinStream && (opts.REPL || opts.JSONLPath != "" || opts.ImagePath != "" || opts.OutputPath != "" || len(opts.InputFiles) > 0 || opts.SchemaPath != "" || opts.Candidates > 1 || opts.Diff || opts.EnglishOnly || len(opts.Chain) > 0) {
+		flagSet.Usage()
+		return opts, errorf("err.stdinStreamConflict")
+	}
+	if opts.StdinStream && flagSet.NArg() > 0 {
+		flagSet.Usage()
+		return opts, errorf("err.stdinStreamWithInput")
+	}
+
+	// メニューから入力テキストを入力した場合はそれを使う
+	if menuInput != "" {
+		opts.InputText = menuInput
+		return opts, nil
 	}
 
+	// 入力ファイルを指定した場合は、入力テキストの引数は指定できない
 	args := flagSet.Args()
-	if len(args) < 1 {
+	if len(opts.InputFiles) > 0 {
+		if len(args) > 0 {
+			flagSet.Usage()
+			return opts, errorf("err.fileWithInput")
+		}
+		return opts, nil
+	}
+
+	// 画像が指定されている場合や対話モード、JSONLや標準入力のストリームの入力では入力テキストを省略できる
+	if len(args) < 1 && opts.ImagePath == "" && !opts.REPL && opts.JSONLPath == "" && !opts.StdinStream {
 		flagSet.Usage()
-		return "", false, "", false, defaultTask, "", fmt.Errorf("入力テキストが指定されていません")
+		return opts, errorf("err.inputRequired")
+	}
+
+	opts.InputText = strings.Join(args, " ")
+	return opts, nil
+}
+
+// 設定から使用するプロファイルを選択し、-backend、-base-urlフラグの指定を反映したコピーを返す
+// 設定ファイルが存在しない (settingsがnil) 場合は対話型セットアップを実行する
+func selectProfile(opts cliOptions, settings *Settings) (*Settings, *Profile, error) {
+	if settings == nil {
+		var err error
+		settings, err = setupInteractive(nil)
+		if err != nil {
+			return nil, nil, errorf("err.setup", err)
+		}
+	}
+
+	profile, _, err := settings.resolveProfile(opts.Profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// -backendフラグが指定された場合はプロファイルのAPIメソッドを上書き
+	if opts.Backend != "" {
+		profile, err = profile.withBackend(opts.Backend)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// プロファイルでAPIのバージョンを指定していない場合は設定ファイル全体の値を使う
+	if profile.APIVersion == "" && settings.APIVersion != "" {
+		overridden := *profile
+		overridden.APIVersion = settings.APIVersion
+		profile = &overridden
 	}
 
-	inputText = strings.Join(args, " ")
-	return modelName, thinkingFlag, thinkingLevel, initFlag, parsedTask, inputText, nil
+	// -base-urlフラグが指定された場合はプロファイルのベースURLを上書き
+	if opts.BaseURL != "" {
+		overridden := *profile
+		overridden.BaseURL = opts.BaseURL
+		profile = &overridden
+	}
+	return settings, profile, nil
+}
+
+// ストリーミング表示の設定を解決する
+// 標準出力が端末でない場合や -no-stream の場合は、遅延を挟まずに届いたテキストをそのまま出力する
+func resolveStreaming(opts cliOptions, streaming StreamingSettings) (int, time.Duration, error) {
+	charsPerStep, delay, err := streaming.resolve(opts.CharsPerStep, opts.MillisPerChar)
+	if err != nil {
+		return 0, 0, err
+	}
+	if opts.NoStream || !isTerminal(os.Stdout) {
+		delay = 0
+	}
+	return charsPerStep, delay, nil
+}
+
+// -list-modelsの処理を実行し、終了コードを返す
+func runListModels(opts cliOptions) int {
+	settings, err := loadSettings()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, msgf("err.loadSettings", err))
+		return exitUsage
+	}
+	_, profile, err := selectProfile(opts, settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, _, err := initClient(ctx, profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitAuth
+	}
+
+	models, err := cachedAvailableModels(ctx, client)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitCodeForError(err)
+	}
+	printModels(os.Stdout, filterModels(models, opts.ModelFilter))
+	return exitOK
 }
 
 func main() {
 	// コマンドライン引数の解析と検証
-	modelName, thinkingFlag, thinkingLevel, initFlag, task, inputText, err := parseArgs()
+	opts, err := parseArgs()
 	if err != nil {
 		if errors.Is(err, flag.ErrHelp) {
-			os.Exit(0)
+			os.Exit(exitOK)
 		}
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	// -configフラグが指定された場合は設定ファイルのパスを上書き
+	if opts.ConfigPath != "" {
+		settingsPathOverride = opts.ConfigPath
+	}
+
+	// -debugフラグが指定された場合はレスポンス構造を標準エラー出力に表示する
+	if opts.Debug {
+		debugOutput = os.Stderr
+	}
+
+	// -refresh-modelsフラグが指定された場合はモデル一覧のキャッシュを使わない
+	refreshModelCache = opts.RefreshModels
+
+	// モデルの一覧に表示するモデルの最大数
+	modelsLimit = opts.ModelsLimit
+
+	// メタデータの表示形式
+	metadataFormat = opts.MetadataFormat
+
+	// -eventsフラグが指定された場合は、警告やエラーも含めて標準エラー出力には進捗イベントのみを出力する
+	if opts.Events {
+		events = newEventEmitter(os.Stderr)
+	}
+
+	// APIキーの環境変数などを-env-file、なければカレントディレクトリの.envから読み込む
+	// 読み込めない場合も警告のみで続行する (.envがないことは警告しない)
+	envFile := opts.EnvFile
+	if envFile == "" {
+		envFile = defaultEnvFile
+	}
+	if found, err := loadEnvFile(envFile); err != nil {
+		printNotice(msgf("warn.prefix", err))
+	} else if !found && opts.EnvFile != "" {
+		printNotice(msgf("warn.envFileNotFound", opts.EnvFile))
 	}
 
 	// -initフラグが指定された場合は対話型セットアップを実行して終了
-	if initFlag {
-		fmt.Println("設定を初期化します...")
-		_, err := setupInteractive()
+	if opts.InitFlag {
+		fmt.Println(msg("init.start"))
+		// 既存の設定がある場合はプロファイルを追加・上書きする
+		// 全ユーザー共通の設定をユーザーの設定ファイルに書き込まないよう、ユーザーの設定のみを読み込む
+		existing, err := loadUserSettings()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, msgf("err.loadSettings", err))
+			os.Exit(exitUsage)
+		}
+		_, err = setupInteractive(existing)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, msgf("err.init", err))
+			os.Exit(exitUsage)
+		}
+		fmt.Println(msg("init.done"))
+		return
+	}
+
+	// -completionフラグが指定された場合は補完スクリプトを出力して終了
+	if opts.Completion != "" {
+		if err := writeCompletion(os.Stdout, opts.Completion, filepath.Base(os.Args[0]), opts.CompletionFlags); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+		return
+	}
+
+	// -historyフラグが指定された場合は履歴を表示して終了
+	if opts.History {
+		os.Exit(runHistory(opts.HistoryLimit, opts.HistorySearch))
+	}
+
+	// -checkフラグが指定された場合は設定と認証情報を確認して終了
+	if opts.Check {
+		os.Exit(runCheck(opts))
+	}
+
+	// -list-modelsフラグが指定された場合はモデル一覧を表示して終了
+	if opts.ListModels {
+		os.Exit(runListModels(opts))
+	}
+
+	// -fileフラグが指定された場合は送信前にすべてのファイルを読み込んで入力テキストにする
+	if len(opts.InputFiles) > 0 {
+		opts.InputText, err = readInputFiles(opts.InputFiles, opts.InputEncoding)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "設定の初期化中にエラーが発生しました: %v\n", err)
-			os.Exit(1)
+			exitWithError(err, exitUsage)
 		}
-		fmt.Println("設定の初期化が完了しました。")
+	}
+
+	// -detect-onlyフラグが指定された場合は言語を判定して出力し、翻訳せずに終了
+	if opts.DetectOnly {
+		lang, confidence := detectLanguage(opts.InputText)
+		fmt.Printf("%s\t%.2f\n", lang, confidence)
 		return
 	}
 
+	// -detectフラグが指定された場合、翻訳不要な入力はそのまま出力して終了
+	if opts.Detect {
+		if opts.Task.Name != "translate" {
+			exitWithError(errorf("err.detectTask", opts.Task.Name), exitUsage)
+		}
+		if opts.ImagePath == "" && !isPredominantlyJapanese(opts.InputText) {
+			printNotice(msgf("status.notJapanese", japaneseRatio(opts.InputText)*100))
+			fmt.Println(opts.InputText)
+			events.done()
+			return
+		}
+	}
+
+	// -imageフラグが指定された場合は画像を読み込む
+	var image *imageInput
+	if opts.ImagePath != "" {
+		image, err = loadImage(opts.ImagePath)
+		if err != nil {
+			exitWithError(err, exitUsage)
+		}
+	}
+
+	// -schemaフラグが指定された場合はJSONスキーマを読み込む
+	var responseSchema any
+	if opts.SchemaPath != "" {
+		responseSchema, err = loadResponseSchema(opts.SchemaPath)
+		if err != nil {
+			exitWithError(err, exitUsage)
+		}
+	}
+
 	// 設定の読み込みまたは対話型セットアップ
 	settings, err := loadSettings()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "設定の読み込み中にエラーが発生しました: %v\n", err)
-		os.Exit(1)
+		exitWithError(errorf("err.loadSettings", err), exitUsage)
 	}
 
-	// 設定ファイルが存在しない場合は対話型セットアップを実行
-	if settings == nil {
-		settings, err = setupInteractive()
+	// LLMリクエストと生成コンテンツの設定作成
+	reqOpts := requestOptions{
+		ModelName:      opts.ModelName,
+		Thinking:       opts.ThinkingFlag,
+		HideThoughts:   opts.NoThoughts,
+		ThinkingSet:    opts.ThinkingSet,
+		ThinkingLevel:  opts.ThinkingLevel,
+		ThinkingBudget: opts.ThinkingBudget,
+		Grounding:      opts.Ground,
+		Tone:           opts.Tone,
+		Bullets:        opts.Bullets,
+		MaxTotalTokens: int32(opts.MaxTotalTokens),
+		MaxTokens:      int32(opts.MaxTokens),
+		Romaji:         opts.Romaji,
+		CandidateCount: int32(opts.Candidates),
+		ResponseSchema: responseSchema,
+		MaxDuration:    opts.MaxDuration,
+		Seed:           opts.Seed,
+		StopSequences:  opts.StopSequences,
+		Pick:           opts.Pick,
+		SystemPrefix:   opts.SystemPrefix,
+	}
+	if settings != nil {
+		reqOpts.TargetLanguageInstruction = settings.targetLanguageInstruction(opts.Task.TargetLanguage)
+		// -system-prefixが指定されていない場合は設定ファイルのsystemPrefixを使う
+		if reqOpts.SystemPrefix == "" {
+			reqOpts.SystemPrefix = settings.SystemPrefix
+		}
+		reqOpts.DefaultThinking = settings.DefaultThinking
+		reqOpts.DefaultThinkingLevel = settings.DefaultThinkingLevel
+		reqOpts.DefaultThinkingBudget = settings.DefaultThinkingBudget
+	}
+
+	// 用語集を使うタスクでは-glossary、なければ設定ファイルのglossaryPathの用語集を読み込む
+	glossaryPath := opts.GlossaryPath
+	usesGlossary := opts.Task.UsesGlossary || slices.ContainsFunc(opts.Chain, func(task TaskDefinition) bool { return task.UsesGlossary })
+	if glossaryPath == "" && settings != nil && usesGlossary {
+		glossaryPath = settings.GlossaryPath
+	}
+	if glossaryPath != "" {
+		entries, err := loadGlossary(glossaryPath)
+		if err != nil {
+			exitWithError(err, exitUsage)
+		}
+		reqOpts.Glossary = formatGlossary(entries)
+	}
+	// -chainの場合は先頭のステージに適用するオプションで組み立てる (-dry-runなどの送信前の確認に使う)
+	firstReqOpts := reqOpts
+	if len(opts.Chain) > 0 {
+		firstReqOpts = stageRequestOptions(opts.Task, reqOpts)
+	}
+	llmReqConfig, genaiConfig, err := createLLMConfigs(opts.Task, opts.InputText, image, firstReqOpts)
+	if err != nil {
+		exitWithError(err, exitUsage)
+	}
+
+	// Gemini 3以外のモデルでは思考レベルは使われず、思考予算で思考の量を指定するため、-think-levelを指定した場合は警告する
+	if strings.TrimSpace(opts.ThinkingLevel) != "" && !isGemini3Model(llmReqConfig.Model) {
+		printNotice(msgf("warn.thinkLevelIgnored", llmReqConfig.Model, *llmReqConfig.ThinkingBudget))
+	}
+
+	// 最大出力トークン数には思考のトークンも含まれるため、思考予算を下回る場合は警告する
+	if opts.MaxTokens > 0 && llmReqConfig.ThinkingBudget != nil && llmReqConfig.MaxTokens < *llmReqConfig.ThinkingBudget {
+		printNotice(msgf("warn.maxTokensBelowBudget", llmReqConfig.MaxTokens, *llmReqConfig.ThinkingBudget))
+	}
+
+	// -dry-runフラグが指定された場合は組み立てたリクエストを表示して終了
+	if opts.DryRun {
+		printDryRun(llmReqConfig)
+		return
+	}
+
+	// -verboseフラグが指定された場合は解決したリクエスト設定を表示する
+	if opts.Verbose {
+		printVerbose(llmReqConfig, opts.Task.Name, opts.InputFiles)
+	}
+
+	// -outputフラグが指定された場合はAPIを呼び出す前に出力先を確認する
+	if opts.OutputPath != "" {
+		if err := checkOutputPath(opts.OutputPath, opts.Force); err != nil {
+			exitWithError(err, exitUsage)
+		}
+	}
+
+	// 使用するプロファイルの選択 (設定ファイルが存在しない場合は対話型セットアップを実行)
+	settings, profile, err := selectProfile(opts, settings)
+	if err != nil {
+		exitWithError(err, exitUsage)
+	}
+
+	// 入力が設定ファイルのconfirmOverCharsを超える場合は、端末から実行されていれば送信前に確認する (-yesと-eventsでは省略)
+	if settings.ConfirmOverChars > 0 && !opts.Yes && !opts.Events && !opts.CountOnly && !opts.REPL && opts.JSONLPath == "" && !opts.StdinStream && isTerminal(os.Stdin) &&
+		utf8.RuneCountInString(opts.InputText) > settings.ConfirmOverChars {
+		ok, err := confirmLargeInput(os.Stdin, os.Stderr, opts.InputText)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "設定のセットアップ中にエラーが発生しました: %v\n", err)
-			os.Exit(1)
+			exitWithError(err, exitGeneral)
 		}
+		if !ok {
+			printNotice(msg("status.sendCancelled"))
+			os.Exit(exitGeneral)
+		}
+	}
+
+	// -rpsフラグまたは設定ファイルのrequestsPerSecondが指定された場合はリクエストの送信間隔を制限する
+	rps, err := resolveRequestsPerSecond(opts.RequestsPerSecond, settings)
+	if err != nil {
+		exitWithError(err, exitUsage)
 	}
+	requestLimiter = newRequestLimiter(rps)
+
+	// Ctrl-C (SIGINT) でストリーミングをキャンセルできるようにする
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// 標準出力のパイプが閉じられた場合にシグナルで終了せず、書き込みエラーとして扱う
+	signal.Ignore(syscall.SIGPIPE)
 
 	// クライアントの初期化
-	ctx := context.Background()
-	client, apiMethod, err := initClient(ctx, settings)
+	client, apiMethod, err := initClient(ctx, profile)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-
-	// 出力処理用のチャネルとgoroutineの設定
-	outputChan := make(chan string, 100)
-	done := make(chan bool)
-
-	go func() {
-		charLengthPerStep := 5
-		timePerChar := 15 * time.Millisecond
-		lastTextEndedWithNewline := false
-
-		for text := range outputChan {
-			var start = 0
-			for start < len(text) {
-				end := min(start+charLengthPerStep, len(text))
-				fmt.Print(text[start:end])
-				start = end
-				// 最後のチャンクでなければ待機
-				if start < len(text) {
-					time.Sleep(timePerChar)
-				}
-			}
+		exitWithError(err, exitAuth)
+	}
+
+	// -audit-logフラグまたは設定ファイルのauditLogPathが指定された場合はリクエストごとに監査ログを記録する
+	if auditLogPath := resolveAuditLogPath(opts.AuditLogPath, settings); auditLogPath != "" {
+		auditLog = newAuditLogger(auditLogPath, apiMethod)
+	}
+
+	// 思考プロセスのテキストの色 (フラグを優先し、次に設定ファイルの値を使う)
+	thoughtColorName := settings.ThoughtColor
+	if opts.ThinkColor != "" {
+		thoughtColorName = opts.ThinkColor
+	}
+	thoughtColor := resolveThoughtColor(thoughtColorName)
+
+	// -check-modelフラグが指定された場合は送信前にモデルが利用可能か確認する
+	if opts.CheckModel {
+		if err := checkModelAvailable(ctx, client, opts.ModelName); err != nil {
+			exitWithError(err, exitCodeForError(err))
+		}
+	}
+
+	// -count-onlyフラグが指定された場合はプロンプトのトークン数と料金の見積もりを表示し、生成せずに終了
+	if opts.CountOnly {
+		tokens, err := countPromptTokens(ctx, client.Models, llmReqConfig, client.ClientConfig().Backend == genai.BackendVertexAI)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeForError(err))
+		}
+		printTokenCount(os.Stdout, llmReqConfig.Model, tokens)
+		return
+	}
+
+	// -replフラグが指定された場合は対話モードを実行して終了
+	if opts.REPL {
+		charsPerStep, delay, err := resolveStreaming(opts, settings.Streaming)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+		err = runREPL(ctx, client, apiMethod, opts.Task, reqOpts, charsPerStep, delay, thoughtColor, !opts.NoSpinner, opts.InputText)
+		if ctx.Err() != nil {
+			stop()
+			fmt.Fprintln(os.Stderr, msg("status.interrupted"))
+			os.Exit(exitInterrupted)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeForError(err))
+		}
+		return
+	}
 
-			// 最後のテキストが改行かどうかを記録
-			if len(text) > 0 && text[len(text)-1] == '\n' {
-				lastTextEndedWithNewline = true
-			} else {
-				lastTextEndedWithNewline = false
+	// -chainフラグが指定された場合はタスクを順に実行して終了
+	if len(opts.Chain) > 0 {
+		charsPerStep, delay, err := resolveStreaming(opts, settings.Streaming)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+		err = runChain(ctx, client.Models, apiMethod, opts.Chain, reqOpts, charsPerStep, delay, thoughtColor, !opts.NoSpinner, opts.InputText)
+		if ctx.Err() != nil {
+			stop()
+			fmt.Fprintln(os.Stderr, msg("status.interrupted"))
+			os.Exit(exitInterrupted)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeForError(err))
+		}
+		return
+	}
+
+	// 一時的なエラーの場合に再試行する最大回数
+	retries := resolveRetries(opts.Retries, settings)
+	if retries < 0 {
+		exitWithError(errorf("err.negativeSettingsRetries", retries), exitUsage)
+	}
+
+	// -jsonlフラグが指定された場合はJSONLの各レコードを処理して終了
+	if opts.JSONLPath != "" {
+		input := os.Stdin
+		if opts.JSONLPath != "-" {
+			input, err = os.Open(opts.JSONLPath)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, msgf("err.openJSONL", err))
+				os.Exit(exitUsage)
 			}
+			defer input.Close()
+		}
+		// -input-encodingが指定された場合はUTF-8に変換しながら読み込む
+		var reader io.Reader = input
+		if opts.InputEncoding != nil {
+			reader = opts.InputEncoding.NewDecoder().Reader(input)
+		}
+		summary, err := runJSONLBatch(ctx, client.Models, reader, os.Stdout, opts.Task, reqOpts, opts.Concurrency, retries)
+		summary.print()
+		if ctx.Err() != nil {
+			stop()
+			fmt.Fprintln(os.Stderr, msg("status.interrupted"))
+			os.Exit(exitInterrupted)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeForError(err))
 		}
+		return
+	}
 
-		// 最後のテキストが改行でなければ改行を出力
-		if !lastTextEndedWithNewline {
-			fmt.Println()
+	// -stdin-streamフラグが指定された場合は標準入力の各行を読み込むたびに処理して終了
+	if opts.StdinStream {
+		// -input-encodingが指定された場合はUTF-8に変換しながら読み込む
+		var reader io.Reader = os.Stdin
+		if opts.InputEncoding != nil {
+			reader = opts.InputEncoding.NewDecoder().Reader(os.Stdin)
+		}
+		summary, err := runStdinStream(ctx, client.Models, reader, os.Stdout, opts.Task, reqOpts, retries)
+		summary.print()
+		if ctx.Err() != nil {
+			stop()
+			fmt.Fprintln(os.Stderr, msg("status.interrupted"))
+			os.Exit(exitInterrupted)
 		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeForError(err))
+		}
+		return
+	}
 
-		done <- true
-	}()
+	// -compareフラグが指定された場合は同じ入力を各モデルで実行し、結果を比較して終了
+	if len(opts.CompareModels) > 0 {
+		err = runCompare(ctx, client.Models, opts.Task, opts.CompareModels, reqOpts, image, opts.InputText, retries, os.Stdout)
+		if ctx.Err() != nil {
+			stop()
+			fmt.Fprintln(os.Stderr, msg("status.interrupted"))
+			os.Exit(exitInterrupted)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeForError(err))
+		}
+		return
+	}
 
-	// LLMリクエストと生成コンテンツの設定作成
-	llmReqConfig, genaiConfig, err := createLLMConfigs(task, modelName, inputText, thinkingFlag, thinkingLevel)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	// -preflightフラグが指定された場合は送信前に入力トークン数を確認する
+	var preflightTokens int32
+	if opts.Preflight != "" {
+		preflightTokens, err = preflightTokenCount(ctx, client.Models, llmReqConfig, opts.Preflight)
+		if err != nil {
+			exitWithError(err, exitCodeForError(err))
+		}
+	}
+
+	// 出力先の設定
+	// -output、-schema、-diff、-english-onlyフラグが指定された場合は結果をバッファに溜め、思考プロセスのみ標準エラー出力に表示する
+	// それ以外の場合はtypewriterで標準出力にストリーミング表示する
+	// 標準出力の読み手が終了した (パイプが閉じられた) 場合は、ストリームを中断してトークンを無駄にしない
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	// -output-encodingが指定された場合は、標準出力に書き込む結果を指定した文字コードに変換する
+	var stdout io.Writer = os.Stdout
+	var encodedStdout io.WriteCloser
+	if opts.EventsOnly {
+		// -events-onlyフラグが指定された場合は結果を標準出力に表示しない
+		stdout = io.Discard
+	} else if opts.OutputEncoding != nil {
+		encodedStdout = newEncodingWriter(os.Stdout, opts.OutputEncoding)
+		stdout = encodedStdout
+	}
+	var brokenPipe atomic.Bool
+	var out, thoughtOut io.Writer
+	var output *typewriter
+	var result bytes.Buffer
+	if opts.OutputPath != "" || responseSchema != nil || opts.Diff || opts.EnglishOnly || opts.EventsOnly {
+		out, thoughtOut = &result, os.Stderr
+		// -eventsフラグが指定された場合、思考プロセスはthoughtイベントでのみ出力する
+		if opts.Events {
+			thoughtOut = io.Discard
+		}
+	} else {
+		charsPerStep, delay, err := resolveStreaming(opts, settings.Streaming)
+		if err != nil {
+			exitWithError(err, exitUsage)
+		}
+		output = newTypewriter(stdout, charsPerStep, delay)
+		output.verbatim = opts.Verbatim
+		output.onWriteError = func(err error) {
+			if isBrokenPipe(err) {
+				brokenPipe.Store(true)
+				cancelStream()
+			}
+		}
+		out, thoughtOut = output, output
+	}
+
+	// -wrapフラグが指定された場合は結果のみを折り返す (思考プロセスは折り返さない)
+	var wrapped *wrapWriter
+	if opts.WrapWidth > 0 && output != nil {
+		wrapped = newWrapWriter(output, opts.WrapWidth)
+		out = wrapped
+	}
+	// -strip-boldフラグが指定された場合は結果から太字の ** を取り除いて表示する
+	var boldStripped *boldStripWriter
+	if opts.StripBold && output != nil {
+		boldStripped = newBoldStripWriter(out)
+		out = boldStripped
+	}
+
+	thoughtOut = colorWriter{thoughtOut, thoughtColor}
+
+	// -eventsフラグが指定された場合は、折り返しなどを行う前のテキストを差分のイベントとして出力する
+	if opts.Events {
+		out = io.MultiWriter(out, eventDeltaWriter{events, eventChunk})
+		thoughtOut = io.MultiWriter(thoughtOut, eventDeltaWriter{events, eventThought})
+	}
+
+	// 履歴を記録する場合は出力を保持する
+	var historyOutput strings.Builder
+	if settings.SaveHistory {
+		out = io.MultiWriter(out, &historyOutput)
+	}
+
+	// 最初のトークンが届くまでスピナーを表示する (-eventsの出力と混ざらないよう、-eventsでは表示しない)
+	var spin *spinner
+	if !opts.NoSpinner && !opts.Events {
+		spin = startSpinner()
+		out, thoughtOut = spinnerStopWriter{out, spin}, spinnerStopWriter{thoughtOut, spin}
 	}
 
 	// ストリーミングAPI呼び出しと結果処理
-	metadata, err := streamContent(ctx, client, llmReqConfig, genaiConfig, outputChan)
+	// 一時的なエラーの場合は、まだ何も出力していなければ最大retries回やり直す
+	// クォータ超過で予備のAPIキーがある場合はキーを切り替え、それ以外は待機時間を倍にしながら待つ
+	var written bool
+	out, thoughtOut = writeTracker{out, &written}, writeTracker{thoughtOut, &written}
+	keyCount := 0
+	if profile.APIMethod == "apiKey" {
+		keyCount = len(profile.APIKeyConfig.candidates())
+	}
+	keyIndex := 0
+	events.start(opts.Task.Name, llmReqConfig.Model, apiMethod)
+	metadata, err := streamContent(streamCtx, client.Models, llmReqConfig, genaiConfig, out, thoughtOut)
+	for attempt := 1; err != nil && isTransientError(err) && !written && attempt <= retries; attempt++ {
+		if isQuotaError(err) && keyIndex+1 < keyCount {
+			keyIndex++
+			printRetry(msgf("status.switchAPIKey", keyIndex, keyIndex+1), attempt, retries, 0)
+			client, _, err = initClientWithKey(ctx, profile, keyIndex)
+			if err != nil {
+				break
+			}
+		} else {
+			delay := retryDelay(attempt)
+			printRetry(msgf("status.retry", delay, attempt, retries, err), attempt, retries, delay)
+			if err = waitRetry(streamCtx, delay); err != nil {
+				break
+			}
+		}
+		metadata, err = streamContent(streamCtx, client.Models, llmReqConfig, genaiConfig, out, thoughtOut)
+	}
+
+	// 指定したモデルが見つからない場合は、まだ何も出力していなければフォールバックのモデルを順に試す
+	var notFound *modelNotFoundError
+	for _, fallback := range resolveModelFallbacks(opts.ModelFallbacks, settings) {
+		if !errors.As(err, &notFound) || written {
+			break
+		}
+		printNotice(msgf("status.modelFallback", llmReqConfig.Model, fallback))
+		fallbackOpts := reqOpts
+		fallbackOpts.ModelName = fallback
+		llmReqConfig, genaiConfig, err = createLLMConfigs(opts.Task, opts.InputText, image, fallbackOpts)
+		if err != nil {
+			break
+		}
+		metadata, err = streamContent(streamCtx, client.Models, llmReqConfig, genaiConfig, out, thoughtOut)
+	}
+	metadata.APIKeyIndex = keyIndex
+	metadata.APIKeyCount = keyCount
+	metadata.APIVersion = client.ClientConfig().HTTPOptions.APIVersion
+	spin.Stop()
 
-	// 出力チャネルをクローズし、出力ゴルーチンの終了を待つ
-	close(outputChan)
-	<-done
+	// 残りの出力をすべて書き出す
+	if boldStripped != nil {
+		boldStripped.Flush()
+	}
+	if wrapped != nil {
+		wrapped.Flush()
+	}
+	if output != nil {
+		output.Close()
+	}
+
+	// 標準出力のパイプが閉じられた場合は、読み手が必要な分を受け取ったとみなして正常終了する
+	if brokenPipe.Load() {
+		events.done()
+		os.Exit(exitOK)
+	}
+
+	// SIGINTによるキャンセルの場合は出力を整えて終了コード130で終了
+	if ctx.Err() != nil {
+		stop()
+		if events != nil {
+			events.fail(ctx.Err(), exitInterrupted)
+		} else {
+			fmt.Fprintln(os.Stderr, msg("status.interrupted"))
+		}
+		os.Exit(exitInterrupted)
+	}
+
+	// 出力の途中でエラーが発生した場合は、途中までの結果を失わないよう標準出力に書き出し、不完全であることを表示する
+	var incomplete *incompleteOutputError
+	if errors.As(err, &incomplete) {
+		if output == nil {
+			stdout.Write(result.Bytes())
+			if !bytes.HasSuffix(result.Bytes(), []byte("\n")) {
+				io.WriteString(stdout, "\n")
+			}
+		}
+		if encodedStdout != nil {
+			encodedStdout.Close()
+		}
+		if events != nil {
+			events.fail(err, exitIncomplete)
+		} else {
+			fmt.Fprintln(os.Stderr, msgf("status.incompleteOutput", incomplete.Err))
+		}
+		os.Exit(exitIncomplete)
+	}
 
 	// エラーハンドリング
 	if err != nil {
-		if !strings.Contains(err.Error(), "見つからないか、generateContentをサポートしていません") {
-			log.Fatal(err)
-		} else {
-			os.Exit(1)
+		code := exitCodeForError(err)
+		if events != nil {
+			// -eventsの場合はモデルの一覧を表示せず、errorイベントのみを出力する
+			exitWithError(err, code)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		if code == exitModelNotFound {
+			listAvailableModels(ctx, client)
+		}
+		os.Exit(code)
+	}
+
+	// -strip-boldフラグが指定された場合は、溜めていた結果から太字の ** を取り除く
+	if opts.StripBold && output == nil {
+		stripped := stripBold(result.String())
+		result.Reset()
+		result.WriteString(stripped)
+	}
+
+	// -schemaフラグが指定された場合は結果がJSONとして解析できるかを確認して整形する
+	body := result.Bytes()
+	if responseSchema != nil {
+		body, err = formatJSONOutput(body)
+		if err != nil {
+			exitWithError(err, exitGeneral)
+		}
+		if opts.OutputPath == "" {
+			stdout.Write(body)
+		}
+	}
+
+	// -english-onlyフラグが指定された場合は出力からENGLISHセクションのみを取り出す
+	// セクションに分けられない場合は警告を表示して出力全体を使う
+	if opts.EnglishOnly {
+		translation := parseTranslationOutput(result.String())
+		if !translation.Parsed {
+			printNotice(msg("warn.englishSectionMissing"))
 		}
+		body = []byte(translation.English + "\n")
+		if opts.OutputPath == "" && !opts.Diff && opts.WrapWidth > 0 {
+			wrapped := newWrapWriter(stdout, opts.WrapWidth)
+			wrapped.Write(body)
+			wrapped.Flush()
+		} else if opts.OutputPath == "" && !opts.Diff {
+			stdout.Write(body)
+		}
+	}
+
+	// -diffフラグが指定された場合は原文と訳文を並べて表示する
+	if opts.Diff {
+		printDiffView(stdout, opts.InputText, string(body), terminalWidth())
+	}
+	if encodedStdout != nil {
+		encodedStdout.Close()
+	}
+
+	// -outputフラグが指定された場合は結果をファイルに書き込む
+	if opts.OutputPath != "" {
+		data, err := encodeText(body, opts.OutputEncoding)
+		if err != nil {
+			exitWithError(err, exitGeneral)
+		}
+		if err := writeOutputFile(opts.OutputPath, data); err != nil {
+			exitWithError(err, exitGeneral)
+		}
+		printNotice(msgf("status.outputWritten", opts.OutputPath))
 	}
 
 	// メタデータの表示
-	printMetadata(metadata, apiMethod, task.Name)
+	metadata.PreflightTokenCount = preflightTokens
+	if opts.Events {
+		events.metadata(newStatsRecord(metadata, apiMethod, opts.Task.Name, llmReqConfig.Model))
+	} else {
+		printMetadata(metadata, apiMethod, opts.Task.Name)
+	}
+
+	// 設定ファイルでsaveHistoryが有効な場合は入力と出力を履歴に記録する
+	if settings.SaveHistory {
+		entry := historyEntry{
+			Timestamp: time.Now(),
+			Task:      opts.Task.Name,
+			Model:     llmReqConfig.Model,
+			Input:     opts.InputText,
+			Output:    historyOutput.String(),
+		}
+		if err := appendHistory(entry); err != nil {
+			printNotice(msgf("warn.historyRecord", err))
+		}
+	}
+
+	// -stats-jsonフラグが指定された場合はメタデータをファイルに追記する
+	if opts.StatsJSONPath != "" {
+		if err := appendStatsJSON(opts.StatsJSONPath, metadata, apiMethod, opts.Task.Name, llmReqConfig.Model); err != nil {
+			exitWithError(err, exitGeneral)
+		}
+	}
+	events.done()
 }
@@ -0,0 +1,680 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CLIのメッセージの言語を指定する環境変数
+const uiLangEnvVar = "LLM_TRANSLATOR_UI_LANG"
+
+// CLIのメッセージの言語 (-langフラグまたは環境変数で指定し、デフォルトは日本語)
+// 翻訳結果などの生成内容には影響しない
+var uiLang = "ja"
+
+// CLIのメッセージのカタログ
+// 言語ごとに同じキーを定義し、キーが見つからない場合は日本語のメッセージを使う
+var messageCatalog = map[string]map[string]string{
+	"ja": {
+		"flag.model":                         "モデル名を指定します",
+		"flag.task":                          "タスク名を指定します (必須)",
+		"flag.think":                         "思考プロセスを有効にします",
+		"flag.think-level":                   "Gemini 3向けの思考レベルを指定します (minimal|low|medium|high)",
+		"flag.think-budget":                  "Gemini 3以外のモデル向けの思考予算 (トークン数) を指定します (デフォルト: 1024)",
+		"flag.history":                       "最近の実行履歴を表示して終了します (設定ファイルの saveHistory が有効な場合に記録されます)",
+		"flag.history-limit":                 "-history で表示する件数を指定します",
+		"flag.history-search":                "入力または出力に指定した語を含む履歴だけを表示して終了します",
+		"flag.init":                          "対話形式で設定を初期化します",
+		"flag.profile":                       "使用する設定プロファイル名を指定します (デフォルト: 設定ファイルのdefaultProfile)",
+		"flag.count-only":                    "生成せずに、システム指示を含むプロンプトのトークン数と入力の料金の見積もりを表示します",
+		"flag.detect-only":                   "入力テキストの言語を文字種から判定し、言語コードと確信度を出力して終了します (翻訳はしません)",
+		"flag.diff":                          "結果をまとめて受け取り、原文と訳文を段落ごとに並べて表示します",
+		"flag.dry-run":                       "APIを呼び出さずに組み立てたプロンプトと設定を表示します",
+		"flag.english-only":                  "translateタスクの出力からENGLISHセクションのみを出力します (CONTEXTは出力しません)",
+		"flag.file":                          "入力テキストを読み込むファイルを指定します (複数回指定すると指定順に連結します)",
+		"flag.image":                         "入力として添付する画像ファイルのパスを指定します (png|jpg|jpeg|webp|heic|heif)",
+		"flag.detect":                        "translateタスクで入力が日本語でない場合はAPIを呼び出さずにそのまま出力します",
+		"flag.system-prefix":                 "すべてのタスクのシステム指示の先頭に加える指示を指定します (例: \"英国式の綴りを使う\"、設定ファイルのsystemPrefixより優先)",
+		"flag.glossary":                      "expandタスクで使う用語集ファイル (各行 \"略語: 説明\") を指定します (設定ファイルのglossaryPathより優先)",
+		"flag.ground":                        "Google検索によるグラウンディングを有効にします (tech-qaタスクのみ)",
+		"flag.tone":                          "translateタスクの翻訳のトーンを指定します (casual|neutral|formal)",
+		"flag.bullets":                       "summarizeタスクで箇条書きで出力します (-bullets=false で文章)",
+		"flag.candidates":                    "生成する候補の数を指定します (1〜8)。2以上の場合は候補ごとに見出しを付けてまとめて出力します",
+		"flag.romaji":                        "出力に日本語が含まれる場合、ローマ字表記を別のセクション (ROMAJI:) に添えます",
+		"flag.output-encoding":               "結果を出力する文字コードを指定します (utf-8|shift-jis|euc-jp、メタデータなど標準エラー出力はUTF-8のまま)",
+		"flag.preflight":                     "送信前に入力トークン数をカウントし、コンテキスト上限を超える場合に警告または中止します (warn|abort)",
+		"flag.output":                        "結果をストリーミング表示せずに指定したファイルへ書き込みます",
+		"flag.force":                         "-output で指定したファイルが既に存在する場合に上書きします",
+		"flag.chars-per-step":                "ストリーミング表示で一度に出力するバイト数を指定します (デフォルト: 設定ファイルの値または5)",
+		"flag.metadata-format":               "実行後に標準エラー出力に表示するメタデータの形式を指定します (table|compact)",
+		"flag.millis-per-char":               "ストリーミング表示の出力間隔 (ミリ秒) を指定します (デフォルト: 設定ファイルの値または15)",
+		"flag.repl":                          "対話モードで起動し、会話の履歴を保持したまま質問を続けます",
+		"flag.debug":                         "APIレスポンスの構造をJSONで標準エラー出力に表示します",
+		"flag.verbose":                       "送信前に解決したリクエスト設定 (モデル、思考、最大トークン数など) を標準エラー出力に表示します",
+		"flag.base-url":                      "Gemini APIのベースURLを上書きします (設定ファイルのbaseUrlより優先)",
+		"flag.audit-log":                     "リクエストごとの監査ログ (入力のハッシュ、トークン数など) をJSON Lines形式で追記するファイルを指定します (設定ファイルのauditLogPathより優先)",
+		"flag.backend":                       "今回の実行で使うAPIメソッド (apiKey または vertexAI) を指定します (設定ファイルのapiMethodより優先)",
+		"flag.check-model":                   "送信前に指定したモデルが利用可能か確認します",
+		"flag.model-fallback":                "指定したモデルが見つからない (404) 場合に順に試すモデルをカンマ区切りで指定します (設定ファイルのmodelFallbacksより優先)",
+		"flag.no-spinner":                    "最初のトークンを待つ間のスピナーを表示しません",
+		"flag.think-color":                   "思考プロセスのテキストの色を指定します (blue|green|cyan|magenta|yellow|red|white|gray|dim|none)",
+		"flag.stats-json":                    "実行後にメタデータをJSON Lines形式で指定したファイルに追記します",
+		"flag.max-duration":                  "1回のリクエストの経過時間の上限 (例: 2m) を指定し、超えた時点でストリーミングを打ち切ります (受信済みの出力は出力します、0で無制限)",
+		"flag.pick":                          "-candidates で生成した候補から1つを選んで出力します (shortest|longest|first、省略時はすべての候補を出力)",
+		"flag.stop":                          "生成を打ち切る文字列を指定します (複数回指定可能、最大5個)",
+		"flag.seed":                          "生成の乱数シードを指定します (対応するモデルで、同じ入力と設定から同じ出力を得やすくなります)",
+		"flag.max-tokens":                    "最大出力トークン数を指定します (デフォルト: 入力の長さとタスクから計算)",
+		"flag.max-total-tokens":              "合計トークン数の上限を指定し、超えた時点でストリーミングを中断します (ベストエフォート、0で無制限)",
+		"flag.strip-bold":                    "結果からMarkdownの太字 (**...**) を取り除きます (コードの中は除く、行単位で表示します)",
+		"flag.wrap":                          "結果を指定した桁数で折り返して表示します (auto で端末の幅、コードブロックは折り返しません)",
+		"flag.verbatim":                      "モデルの出力をそのまま出力し、末尾に改行を補いません (コミットメッセージなどバイト単位で一致させたい場合)",
+		"flag.no-stream":                     "タイプライター風の表示を行わず、届いたテキストをそのまま出力します (標準出力が端末でない場合は常にこの動作)",
+		"flag.no-thoughts":                   "思考は有効にしたまま、思考プロセスのテキストを表示しません",
+		"flag.input-encoding":                "-file や -jsonl で読み込む入力の文字コードを指定します (utf-8|shift-jis|euc-jp)",
+		"flag.jsonl":                         "JSONLファイル ({\"id\": ..., \"text\": ...} の各行) を順に処理し、結果をJSONLで標準出力に書き込みます (- で標準入力)",
+		"flag.retries":                       "一時的なエラーやクォータ超過の場合に再試行する最大回数を指定します (0で再試行しない、デフォルト: 設定ファイルの値または3)",
+		"flag.stdin-stream":                  "標準入力を1行ずつ読み込み、空でない行ごとに処理して結果をすぐに出力します (入力が閉じられるまで続けます)",
+		"flag.events":                        "進捗イベント (start、chunk、thought、metadata、done、error) を1行ずつのJSONとして標準エラー出力に出力します (エディタなどとの連携向け)",
+		"flag.events-only":                   "-events に加え、標準出力への結果の表示を省略します (結果はchunkイベントから組み立てます)",
+		"flag.concurrency":                   "バッチ処理 (-jsonl) で同時に処理するレコード数の上限を指定します",
+		"flag.chain":                         "カンマ区切りのタスクを順に実行し、各タスクの出力を次のタスクの入力にします (例: translate,summarize)",
+		"flag.compare":                       "カンマ区切りのモデルで同じ入力を順に実行し、出力とAPI呼び出し時間、トークン数、入力の料金の見積もりを比較します (例: gemini-2.5-flash,gemini-3-flash-preview)",
+		"flag.yes":                           "入力が設定ファイルの confirmOverChars を超える場合の送信前の確認を省略します",
+		"flag.check":                         "設定、認証情報、モデルを確認して終了します (生成リクエストは送信しません)",
+		"flag.models-limit":                  "モデルの一覧 (-list-models、モデルが見つからない場合の表示) に表示するモデルの最大数を指定します (0で無制限)。名前順に並べるため一覧はすべて取得し、表示数のみを制限します",
+		"flag.list-models":                   "利用可能なモデルの一覧を表示して終了します (引数を指定するとモデル名で絞り込みます)",
+		"flag.rps":                           "1秒あたりの最大リクエスト数を指定し、超えないよう送信前に待機します (0で無制限、デフォルト: 設定ファイルのrequestsPerSecond)",
+		"flag.refresh-models":                "モデル一覧のキャッシュを使わずに取得し直します",
+		"flag.schema":                        "JSONスキーマファイルを指定し、結果をスキーマに沿ったJSONで出力します (ストリーミング表示は行いません)",
+		"flag.completion":                    "指定したシェル (bash|zsh|fish) 向けの補完スクリプトを標準出力に書き込んで終了します",
+		"flag.env-file":                      "APIキーなどの環境変数を読み込むファイルを指定します (デフォルト: カレントディレクトリの .env、設定済みの環境変数は上書きしません)",
+		"flag.config":                        "設定ファイルのパスを指定します (デフォルト: $XDG_CONFIG_HOME/llm-assistant/settings.json)",
+		"flag.lang":                          "CLIのメッセージの言語を指定します (ja|en、環境変数 LLM_TRANSLATOR_UI_LANG でも指定可能)",
+		"usage.usage":                        "Usage: %s [options] <入力テキスト>",
+		"usage.example":                      "Example: %s --task translate \"翻訳したいテキスト\"",
+		"usage.init":                         "Init only: %s -init",
+		"usage.listModels":                   "List models: %s -list-models [フィルタ]",
+		"usage.check":                        "Check settings: %s -check [-model モデル名]",
+		"usage.options":                      "Options:",
+		"usage.tasks":                        "Tasks:",
+		"task.translate":                     "日本語→英語翻訳",
+		"task.tech-qa":                       "技術的な質問に簡潔に回答",
+		"task.summarize":                     "日本語または英語の文書を簡潔に要約",
+		"task.proofread":                     "英文の文法や不自然な表現を校正",
+		"task.explain":                       "コードの動作を簡潔に説明",
+		"task.expand":                        "社内用語集の略語を正式名称と説明に展開",
+		"err.invalidLang":                    "-lang には ja または en を指定してください: %s",
+		"err.invalidCompletion":              "-completion には bash、zsh、fish のいずれかを指定してください: %s",
+		"err.taskRequired":                   "タスク名を --task で指定してください",
+		"err.invalidTask":                    "無効なタスク名が指定されています (-task): %s",
+		"err.inputRequired":                  "入力テキストが指定されていません",
+		"err.loadSettings":                   "設定の読み込み中にエラーが発生しました: %v",
+		"err.setup":                          "設定のセットアップ中にエラーが発生しました: %w",
+		"err.init":                           "設定の初期化中にエラーが発生しました: %v",
+		"status.interrupted":                 "中断されました",
+		"init.start":                         "設定を初期化します...",
+		"init.done":                          "設定の初期化が完了しました。",
+		"menu.task":                          "タスクを選択してください:",
+		"menu.choose":                        "番号を選択してください (1〜%d): ",
+		"menu.input":                         "入力テキストを入力してください (Ctrl-Dで送信):",
+		"menu.invalidChoice":                 "無効な選択です: %s",
+		"setup.notFound":                     "設定ファイルが見つかりません。対話形式で設定を行います。",
+		"setup.registeredProfiles":           "登録済みのプロファイル: %s",
+		"setup.profileName":                  "プロファイル名を入力してください (デフォルト: %s): ",
+		"setup.apiMethod":                    "使用するAPIメソッドを選択してください:",
+		"setup.apiMethodKey":                 "1. APIキーを使用 (Gemini API)",
+		"setup.apiMethodVertex":              "2. Vertex AIを使用",
+		"setup.choose":                       "選択してください (1または2): ",
+		"setup.keySource":                    "APIキーの取得元を選択してください:",
+		"setup.keySourceEnv":                 "1. 環境変数",
+		"setup.keySourceFile":                "2. ファイル",
+		"setup.chooseDefault1":               "選択してください (1または2, デフォルト: 1): ",
+		"setup.envVarName":                   "APIキーが設定されている環境変数名を入力してください (デフォルト: API_KEY_GOOGLE): ",
+		"setup.keyFile":                      "APIキーが書かれたファイルのパスを入力してください: ",
+		"setup.keyFileRequired":              "APIキーファイルのパスは必須です",
+		"setup.invalidChoice":                "無効な選択です: %s",
+		"setup.project":                      "Google CloudプロジェクトIDを入力してください: ",
+		"setup.projectRequired":              "プロジェクトIDは必須です",
+		"setup.location":                     "Vertex AIのリージョンを入力してください (デフォルト: asia-northeast1): ",
+		"setup.makeDefault":                  "このプロファイルをデフォルトにしますか？ (現在: %s) [y/N]: ",
+		"setup.saveFailed":                   "設定の保存に失敗しました: %w",
+		"setup.saved":                        "設定を %s に保存しました。",
+		"setup.profile":                      "プロファイル: %s (APIメソッド: %s)",
+		"setup.defaultProfile":               "デフォルトプロファイル: %s",
+		"err.negativeMaxTotalTokens":         "-max-total-tokens には0以上の値を指定してください: %d",
+		"err.negativeRetries":                "-retries には0以上の値を指定してください: %d",
+		"err.invalidSeed":                    "-seed には%dから%dまでの値を指定してください: %d",
+		"err.invalidMaxTokens":               "-max-tokens には1以上の値を指定してください: %d",
+		"err.invalidBackend":                 "-backend には apiKey または vertexAI を指定してください: %s",
+		"err.invalidHistoryLimit":            "-history-limit には1以上の値を指定してください: %d",
+		"err.negativeModelsLimit":            "-models-limit には0以上の値を指定してください: %d",
+		"err.fileWithInput":                  "-file と入力テキストの引数は同時に指定できません",
+		"err.chainWithTask":                  "-chain と -task は同時に指定できません",
+		"err.replConflict":                   "-repl は -image や -output と同時に指定できません",
+		"err.schemaConflict":                 "-schema は -repl や -jsonl と同時に指定できません",
+		"err.tooManyStops":                   "-stop は最大%d個まで指定できます: %d個",
+		"err.emptyStop":                      "-stop に空の文字列は指定できません",
+		"err.invalidCandidates":              "-candidates には1から%dまでの値を指定してください: %d",
+		"err.candidatesConflict":             "-candidates は -repl、-schema、-jsonl と同時に指定できません",
+		"err.pickWithoutCandidates":          "-pick は -candidates に2以上を指定した場合のみ指定できます",
+		"err.diffConflict":                   "-diff は -repl、-jsonl、-image、-output、-schema、-candidates と同時に指定できません",
+		"err.englishOnlyTask":                "-english-only は translate タスクでのみ指定できます (指定されたタスク: %s)",
+		"err.englishOnlyConflict":            "-english-only は -repl、-jsonl、-schema、-candidates と同時に指定できません",
+		"err.chainConflict":                  "-chain は -repl、-jsonl、-image、-output、-schema、-candidates、-diff、-english-only、-detect と同時に指定できません",
+		"err.compareConflict":                "-compare は -repl、-jsonl、-stdin-stream、-chain、-output、-schema、-candidates、-diff、-english-only、-count-only、-model-fallback、-output-encoding と同時に指定できません",
+		"err.wrapConflict":                   "-wrap は -repl、-jsonl、-stdin-stream、-chain、-compare、-output、-schema、-diff、-verbatim と同時に指定できません",
+		"err.stripBoldConflict":              "-strip-bold は -repl、-jsonl、-stdin-stream、-chain、-compare、-schema、-verbatim と同時に指定できません",
+		"err.countOnlyConflict":              "-count-only は -repl、-jsonl、-stdin-stream、-chain、-output と同時に指定できません",
+		"err.eventsConflict":                 "-events、-events-only は -repl、-jsonl、-stdin-stream、-chain、-compare、-count-only、-verbose、-debug と同時に指定できません",
+		"err.invalidMetadataFormat":          "-metadata-format には table または compact を指定してください: %s",
+		"err.negativeMaxDuration":            "-max-duration には0以上の値を指定してください: %v",
+		"err.outputEncodingConflict":         "-output-encoding は -repl、-jsonl、-stdin-stream と同時に指定できません",
+		"err.invalidConcurrency":             "-concurrency には1以上の値を指定してください: %d",
+		"err.jsonlConflict":                  "-jsonl は -repl、-image、-output、-file と同時に指定できません",
+		"err.stdinStreamConflict":            "-stdin-stream は -repl、-jsonl、-image、-output、-file、-schema、-candidates、-diff、-english-only、-chain と同時に指定できません",
+		"err.stdinStreamWithInput":           "-stdin-stream と入力テキストの引数は同時に指定できません",
+		"warn.prefix":                        "警告: %v",
+		"warn.envFileNotFound":               "警告: 環境変数ファイル '%s' が見つかりません",
+		"err.detectTask":                     "-detect は translate タスクでのみ指定できます (指定されたタスク: %s)",
+		"status.notJapanese":                 "入力テキストは日本語ではないと判定されたため、翻訳せずに出力します (日本語の割合: %.0f%%)",
+		"warn.thinkLevelIgnored":             "警告: モデル '%s' では -think-level は使われません (思考を有効にし、思考予算 %d トークンで実行します)。思考の量は -think-budget で指定してください",
+		"warn.maxTokensBelowBudget":          "警告: -max-tokens (%d) が思考予算 (%d) を下回っているため、出力が途中で終わる可能性があります",
+		"status.sendCancelled":               "送信を中止しました",
+		"err.negativeSettingsRetries":        "設定ファイルの retries には0以上の値を指定してください: %d",
+		"err.openJSONL":                      "JSONLファイルを開けませんでした: %v",
+		"status.switchAPIKey":                "APIキー #%d がクォータを超過したため、APIキー #%d に切り替えます",
+		"status.retry":                       "一時的なエラーのため、%v後に再試行します (%d/%d): %v",
+		"status.modelFallback":               "モデル '%s' が見つからないため、'%s' で再試行します",
+		"status.incompleteOutput":            "[出力が途中で終了しました: %v]",
+		"warn.englishSectionMissing":         "警告: 出力からENGLISHセクションを抽出できなかったため、出力全体を表示します",
+		"status.outputWritten":               "結果を %s に書き込みました",
+		"warn.historyRecord":                 "警告: 履歴を記録できませんでした: %v",
+		"status.moreModels":                  "... 他 %d 件 (-models-limit で表示数を変更できます)",
+		"err.listModels":                     "モデル一覧の取得に失敗しました: %w",
+		"status.availableModels":             "利用可能なモデル:",
+		"err.nextPage":                       "モデル一覧の次のページの取得に失敗しました: %w",
+		"err.apiKeyIndex":                    "APIキーのインデックスが範囲外です: %d",
+		"err.initGeminiClient":               "Gemini APIクライアントの初期化に失敗しました: %w",
+		"err.baseURLBackend":                 "ベースURLの指定はGemini API (apiKey) でのみ使用できます",
+		"err.initVertexClient":               "Vertex AIクライアントの初期化に失敗しました: %w",
+		"err.invalidAPIMethod":               "無効なAPIメソッド: %s",
+		"err.invalidThinkLevel":              "無効な -think-level が指定されました: %s (指定可能: minimal|low|medium|high)",
+		"err.thinkBudgetGemini3":             "Gemini3シリーズでは -think-budget は使用できません。-think-level を指定してください",
+		"err.negativeThinkBudget":            "-think-budget には0以上の値を指定してください: %d",
+		"err.thinkLevelRequired":             "Gemini3シリーズでは -think-level が必須です",
+		"err.thinkLevelLowHigh":              "モデル '%s' では -think-level は low または high のみ指定可能です",
+		"err.imageUnsupported":               "タスク '%s' は画像入力に対応していません",
+		"err.groundUnsupported":              "タスク '%s' では -ground を指定できません",
+		"err.schemaWithGround":               "-schema と -ground は同時に指定できません",
+		"err.serializeResponse":              "レスポンスのシリアライズに失敗しました: %v",
+		"err.apiCall":                        "API呼び出し中にエラーが発生しました: %w",
+		"err.tokenBudgetExceeded":            "aborted: token budget exceeded (合計トークン数 %d が上限 %d を超えました)",
+		"err.maxTokensReached":               "最大出力トークン数に達したため、応答が途中で打ち切られました (finish reason: MAX_TOKENS)。最大トークン数を増やすか、思考予算を減らしてください",
+		"warn.maxDuration":                   "警告: 経過時間の上限 (-max-duration) に達したため、応答が途中で打ち切られました (finish reason: MAX_DURATION)",
+		"warn.unexpectedFinish":              "警告: 応答が想定外の理由で終了しました (finish reason: %s)",
+		"err.emptyResponse":                  "モデルから空の応答が返されました (finish reason: %s)",
+		"err.invalidPick":                    "無効な -pick が指定されました: %s (指定可能: %s|%s|%s)",
+		"err.readAPIKeyFile":                 "APIキーファイル '%s' の読み込みに失敗しました: %w",
+		"err.emptyAPIKeyFile":                "APIキーファイル '%s' が空です",
+		"err.apiKeySourceMissing":            "APIキーの取得元 (環境変数名またはファイル) が設定されていません",
+		"err.apiKeyEnvEmpty":                 "環境変数 '%s' にAPIキーが設定されていません",
+		"err.nonPositive":                    "%s には正の値を指定してください: %d",
+		"err.apiKeyBackendNotConfigured":     "-backend apiKey が指定されましたが、プロファイルにAPIキーの取得元が設定されていません (-init で設定してください)",
+		"err.vertexBackendNotConfigured":     "-backend vertexAI が指定されましたが、プロファイルにVertex AIのプロジェクトまたはロケーションが設定されていません (-init で設定してください)",
+		"err.noDefaultProfile":               "デフォルトプロファイルが設定されていません。-profile で指定してください",
+		"err.profileNotFound":                "プロファイル '%s' が見つかりません (登録済み: %s)",
+		"err.homeDir":                        "ホームディレクトリの取得に失敗しました: %w",
+		"err.invalidDefaultThinkingLevel":    "設定ファイルの defaultThinkingLevel が無効です: %s (指定可能: minimal|low|medium|high)",
+		"err.negativeDefaultThinkingBudget":  "設定ファイルの defaultThinkingBudget には0以上の値を指定してください: %d",
+		"err.invalidDefaultTask":             "設定ファイルの defaultTask が無効です: %s",
+		"err.negativeConfirmOverChars":       "設定ファイルの confirmOverChars には0以上の値を指定してください: %d",
+		"err.readSettings":                   "設定ファイル '%s' の読み込みに失敗しました: %w",
+		"err.parseSettings":                  "設定ファイル '%s' の解析に失敗しました: %w",
+		"err.serializeSettings":              "設定のシリアライズに失敗しました: %w",
+		"err.saveSettings":                   "設定ファイルの保存に失敗しました: %w",
+		"err.auditLog":                       "監査ログへの記録に失敗しました: %v",
+		"err.createRequestID":                "リクエストIDを作成できませんでした: %w",
+		"err.createDir":                      "ディレクトリを作成できませんでした: %w",
+		"err.lockFile":                       "ファイルをロックできませんでした: %w",
+		"err.readPreviousRecord":             "直前の記録を読み込めませんでした: %w",
+		"err.serializeRecord":                "記録をシリアライズできませんでした: %w",
+		"status.lineError":                   "%d行目: %v",
+		"err.writeResult":                    "結果の書き込みに失敗しました: %w",
+		"err.readInput":                      "入力の読み込みに失敗しました: %w",
+		"status.line":                        "%d行目",
+		"warn.invalidRecord":                 "%d行目: 不正なレコードのためスキップします: %v",
+		"warn.missingIDOrText":               "%d行目: id または text がないためスキップします",
+		"status.batchTokenBudget":            "aborted: token budget exceeded (累計トークン数 %d が上限 %d に達したため、%d行目以降を処理しません)",
+		"status.retryLabeled":                "%s: 一時的なエラーのため、%v後に再試行します (%d/%d): %v",
+		"err.invalidChain":                   "-chain には2つ以上のタスクをカンマ区切りで指定してください: %s",
+		"err.chainStage":                     "ステージ %d (%s): %w",
+		"err.invalidCompare":                 "-compare には2つ以上のモデルをカンマ区切りで指定してください: %s",
+		"status.compareError":                "エラー: %v",
+		"err.compareFailed":                  "%d個のモデルのうち%d個でエラーが発生しました",
+		"check.settingsNotFound":             "%s が見つかりません (-init で作成してください)",
+		"check.configured":                   "設定されています",
+		"check.vertexNotConfigured":          "プロジェクトまたはロケーションが設定されていません",
+		"check.modelsListed":                 "モデル一覧を取得できました (%d件)",
+		"check.modelUnavailable":             "%s は利用できないか、generateContentをサポートしていません",
+		"warn.unknownColor":                  "警告: 不明な色 '%s' が指定されたため %s を使います",
+		"confirm.largeInput":                 "入力が大きいため確認します: 約%d文字 (推定 %d トークン)。送信しますか？ [y/N]: ",
+		"err.countTokens":                    "入力トークン数のカウントに失敗しました: %w",
+		"diff.original":                      "原文",
+		"diff.translation":                   "訳文",
+		"err.openEnvFile":                    "環境変数ファイル '%s' を開けませんでした: %w",
+		"warn.envFileInvalidLine":            "警告: 環境変数ファイル '%s' の%d行目は KEY=VALUE の形式ではないためスキップします",
+		"err.setEnv":                         "環境変数 '%s' の設定に失敗しました: %w",
+		"err.readEnvFile":                    "環境変数ファイル '%s' の読み込みに失敗しました: %w",
+		"err.modelNotFound":                  "指定されたモデル '%s' が見つからないか、generateContentをサポートしていません",
+		"err.modelNotFoundCause":             "指定されたモデル '%s' が見つからないか、generateContentをサポートしていません: %v",
+		"err.contentBlocked":                 "応答がブロックされました (理由: %s)",
+		"err.readGlossary":                   "用語集ファイルの読み込みに失敗しました: %w",
+		"err.parseGlossaryLine":              "用語集ファイル '%s' の%d行目を解析できませんでした (\"略語: 説明\" の形式で指定してください): %s",
+		"err.emptyGlossary":                  "用語集ファイル '%s' に項目がありません",
+		"err.serializeHistory":               "履歴のシリアライズに失敗しました: %w",
+		"err.createHistoryDir":               "履歴ファイルのディレクトリ作成に失敗しました: %w",
+		"err.openHistory":                    "履歴ファイルを開けませんでした: %w",
+		"err.writeHistory":                   "履歴ファイルへの書き込みに失敗しました: %w",
+		"err.readHistory":                    "履歴ファイルの読み込みに失敗しました: %w",
+		"history.input":                      "入力",
+		"history.output":                     "出力",
+		"status.noHistory":                   "履歴がありません (%s)。設定ファイルで \"saveHistory\": true を指定すると実行ごとに記録されます",
+		"warn.openHistory":                   "履歴ファイルを開けませんでした: %v",
+		"err.unsupportedImage":               "サポートされていない画像形式です: %s (対応形式: png, jpg, jpeg, webp, heic, heif)",
+		"err.readImage":                      "画像ファイルの読み込みに失敗しました: %w",
+		"err.inputFileNotFound":              "入力ファイル '%s' が見つかりません: %w",
+		"err.inputFileIsDir":                 "入力ファイル '%s' はディレクトリです",
+		"err.readInputFile":                  "入力ファイル '%s' の読み込みに失敗しました: %w",
+		"err.inputFile":                      "入力ファイル '%s': %w",
+		"warn.saveModelCache":                "警告: モデル一覧のキャッシュを保存できませんでした: %v",
+		"err.statOutput":                     "出力ファイルの確認に失敗しました: %w",
+		"err.outputIsDir":                    "出力先 '%s' はディレクトリです",
+		"err.outputExists":                   "出力ファイル '%s' は既に存在します。上書きする場合は -force を指定してください",
+		"err.createOutputDir":                "出力先ディレクトリの作成に失敗しました: %w",
+		"err.writeOutput":                    "出力ファイルの書き込みに失敗しました: %w",
+		"err.invalidPreflight":               "無効な -preflight が指定されました: %s (指定可能: %s|%s)",
+		"status.contextLimitUnknown":         "モデル '%s' のコンテキスト上限が不明なため、入力トークン数 (%d) の確認をスキップします",
+		"err.contextLimitExceeded":           "入力トークン数 (%d) がモデル '%s' のコンテキスト上限 (%d) を超えています",
+		"err.contextLimitExceededWithOutput": "入力トークン数 (%d) と最大出力トークン数 (%d、思考を含む) の合計 (%d) がモデル '%s' のコンテキスト上限 (%d) を超えています",
+		"err.negativeRPS":                    "1秒あたりのリクエスト数 (-rps、requestsPerSecond) には0以上の値を指定してください: %g",
+		"status.replStart":                   "対話モードを開始します (:reset で履歴を消去、:exit または Ctrl-D で終了)",
+		"status.replReset":                   "履歴を消去しました",
+		"err.readSchema":                     "JSONスキーマファイルの読み込みに失敗しました: %w",
+		"err.parseSchema":                    "JSONスキーマファイル '%s' の解析に失敗しました: %w",
+		"err.schemaNotObject":                "JSONスキーマファイル '%s' はJSONオブジェクトではありません",
+		"err.outputNotJSON":                  "モデルの出力をJSONとして解析できませんでした:\n%s",
+		"err.formatJSON":                     "JSONの整形に失敗しました: %w",
+		"status.waiting":                     "応答を待っています...",
+		"err.serializeStats":                 "統計情報のシリアライズに失敗しました: %w",
+		"err.createStatsDir":                 "統計情報ファイルのディレクトリ作成に失敗しました: %w",
+		"err.openStats":                      "統計情報ファイルを開けませんでした: %w",
+		"err.writeStats":                     "統計情報ファイルへの書き込みに失敗しました: %w",
+		"err.readInputText":                  "入力テキストの読み込みに失敗しました: %w",
+		"err.toneUnsupported":                "タスク '%s' では -tone を指定できません",
+		"err.invalidTone":                    "無効な -tone が指定されました: %s (指定可能: %s)",
+		"err.bulletsUnsupported":             "タスク '%s' では -bullets を指定できません",
+		"err.glossaryUnsupported":            "タスク '%s' では -glossary を指定できません",
+		"err.glossaryRequired":               "タスク '%s' には用語集が必要です。-glossary または設定ファイルの glossaryPath で用語集ファイルを指定してください",
+		"err.invalidEncoding":                "無効な文字コードが指定されました: %s (指定可能: utf-8|shift-jis|euc-jp)",
+		"err.decodeInput":                    "入力の文字コードの変換に失敗しました: %w",
+		"err.encodeOutput":                   "出力の文字コードの変換に失敗しました: %w",
+		"err.invalidWrap":                    "-wrap には1以上の桁数または %s を指定してください: %s",
+	},
+	"en": {
+		"flag.model":                         "Model name",
+		"flag.task":                          "Task name (required)",
+		"flag.think":                         "Enable the thinking process",
+		"flag.think-level":                   "Thinking level for Gemini 3 (minimal|low|medium|high)",
+		"flag.think-budget":                  "Thinking budget (tokens) for models other than Gemini 3 (default: 1024)",
+		"flag.history":                       "Show recent history entries and exit (recorded when saveHistory is enabled in the settings file)",
+		"flag.history-limit":                 "Number of entries shown by -history",
+		"flag.history-search":                "Show only history entries whose input or output contains the term, then exit",
+		"flag.init":                          "Initialize the settings interactively",
+		"flag.profile":                       "Settings profile to use (default: defaultProfile in the settings file)",
+		"flag.count-only":                    "Print the prompt token count (including the system instruction) and an estimated input cost without generating",
+		"flag.detect-only":                   "Detect the language of the input from its script, print the language code and confidence, and exit without translating",
+		"flag.diff":                          "Buffer the result and show the original and the translation side by side, paragraph by paragraph",
+		"flag.dry-run":                       "Print the assembled prompt and settings without calling the API",
+		"flag.english-only":                  "Print only the ENGLISH section of the translate task output (drop the CONTEXT)",
+		"flag.file":                          "File to read the input text from (repeat to concatenate files in order)",
+		"flag.image":                         "Path to an image file to attach as input (png|jpg|jpeg|webp|heic|heif)",
+		"flag.detect":                        "For the translate task, print the input as-is without calling the API if it is not Japanese",
+		"flag.system-prefix":                 "Instruction prepended to the system instruction of every task (e.g. \"Use British English spelling\"; overrides systemPrefix in the settings)",
+		"flag.glossary":                      "Glossary file for the expand task (one \"TERM: definition\" per line; overrides glossaryPath in the settings)",
+		"flag.ground":                        "Enable grounding with Google Search (tech-qa task only)",
+		"flag.tone":                          "Tone of the translation for the translate task (casual|neutral|formal)",
+		"flag.bullets":                       "Output bullet points for the summarize task (-bullets=false for prose)",
+		"flag.candidates":                    "Number of candidates to generate (1-8). With 2 or more, each candidate is printed under a numbered header",
+		"flag.romaji":                        "Add a romaji transliteration in a separate section (ROMAJI:) when the output contains Japanese",
+		"flag.output-encoding":               "Encoding of the result written to stdout or -output (utf-8|shift-jis|euc-jp; stderr stays UTF-8)",
+		"flag.preflight":                     "Count input tokens before sending and warn or abort if the context limit is exceeded (warn|abort)",
+		"flag.output":                        "Write the result to the given file instead of streaming it",
+		"flag.force":                         "Overwrite the file given by -output if it already exists",
+		"flag.chars-per-step":                "Number of bytes written per step when streaming (default: settings file value or 5)",
+		"flag.metadata-format":               "Format of the metadata printed to stderr after a run (table|compact)",
+		"flag.millis-per-char":               "Interval (milliseconds) between steps when streaming (default: settings file value or 15)",
+		"flag.repl":                          "Start an interactive session that keeps the conversation history",
+		"flag.debug":                         "Print the API response structure as JSON to stderr",
+		"flag.verbose":                       "Print the resolved request settings (model, thinking, max tokens, etc.) to stderr before sending",
+		"flag.base-url":                      "Override the Gemini API base URL (takes precedence over baseUrl in the settings file)",
+		"flag.audit-log":                     "Append a per-request audit log (input hash, token counts, etc.) as JSON Lines to this file (overrides auditLogPath in the settings)",
+		"flag.backend":                       "API method for this run (apiKey or vertexAI; takes precedence over apiMethod in the settings file)",
+		"flag.check-model":                   "Check that the model is available before sending",
+		"flag.model-fallback":                "Comma-separated models to try in order when the model is not found (404) (overrides modelFallbacks in the settings)",
+		"flag.no-spinner":                    "Do not show the spinner while waiting for the first token",
+		"flag.think-color":                   "Color of the thinking text (blue|green|cyan|magenta|yellow|red|white|gray|dim|none)",
+		"flag.stats-json":                    "Append the metadata to the given file in JSON Lines format after the run",
+		"flag.max-duration":                  "Maximum wall-clock duration per request (e.g. 2m); the stream is cut off once it elapses, keeping the output received so far (0 for unlimited)",
+		"flag.pick":                          "Print only one of the candidates generated with -candidates (shortest|longest|first; prints all candidates when omitted)",
+		"flag.stop":                          "Stop generation when this string is produced (repeatable, up to 5)",
+		"flag.seed":                          "Random seed for generation (on supporting models, makes outputs reproducible for the same input and settings)",
+		"flag.max-tokens":                    "Maximum number of output tokens (default: computed from the input length and task)",
+		"flag.max-total-tokens":              "Abort streaming once the total token count exceeds this value (best effort, 0 for unlimited)",
+		"flag.strip-bold":                    "Remove Markdown bold (**...**) from the result, except inside code (output is shown line by line)",
+		"flag.wrap":                          "Word-wrap the displayed result at the given column (auto for the terminal width; code blocks are left as is)",
+		"flag.verbatim":                      "Write the model output as-is without adding a trailing newline (for byte-exact output such as commit messages)",
+		"flag.no-stream":                     "Write text as it arrives without the typewriter effect (always the case when stdout is not a terminal)",
+		"flag.no-thoughts":                   "Keep thinking enabled but do not show the thinking text",
+		"flag.input-encoding":                "Encoding of the input read with -file or -jsonl (utf-8|shift-jis|euc-jp)",
+		"flag.jsonl":                         "Process each line of a JSONL file ({\"id\": ..., \"text\": ...}) and write the results as JSONL to stdout (- for stdin)",
+		"flag.retries":                       "Maximum number of retries on transient errors or quota exhaustion (0 disables retries; default: settings file value or 3)",
+		"flag.stdin-stream":                  "Read stdin line by line and print the result for each non-empty line as soon as it is processed (until stdin closes)",
+		"flag.events":                        "Write progress events (start, chunk, thought, metadata, done, error) to stderr as newline-delimited JSON (for editor integrations)",
+		"flag.events-only":                   "Like -events, but do not print the result to stdout (rebuild it from the chunk events)",
+		"flag.concurrency":                   "Maximum number of records processed concurrently in batch mode (-jsonl)",
+		"flag.chain":                         "Run comma-separated tasks in order, feeding each output into the next task (e.g. translate,summarize)",
+		"flag.compare":                       "Run the same input through each comma-separated model in turn and compare the outputs, API call time, token counts, and estimated input cost (e.g. gemini-2.5-flash,gemini-3-flash-preview)",
+		"flag.yes":                           "Skip the confirmation shown before sending input longer than confirmOverChars in the settings file",
+		"flag.check":                         "Check the settings, credentials, and model, then exit (no generation request is sent)",
+		"flag.models-limit":                  "Maximum number of models shown in model lists (-list-models and when a model is not found; 0 for no limit). The full list is still fetched so it can be sorted by name; only the output is limited",
+		"flag.list-models":                   "List the available models and exit (an argument filters by model name)",
+		"flag.rps":                           "Limit requests per second, waiting before each request as needed (0 for unlimited; default: requestsPerSecond in the settings)",
+		"flag.refresh-models":                "Fetch the model list again without using the cache",
+		"flag.schema":                        "Path to a JSON schema file; the result is printed as JSON following the schema (no streaming)",
+		"flag.completion":                    "Write a completion script for the given shell (bash|zsh|fish) to stdout and exit",
+		"flag.env-file":                      "File to load environment variables such as API keys from (default: .env in the current directory; variables already set are not overridden)",
+		"flag.config":                        "Path to the settings file (default: $XDG_CONFIG_HOME/llm-assistant/settings.json)",
+		"flag.lang":                          "Language of the CLI messages (ja|en; can also be set with the LLM_TRANSLATOR_UI_LANG environment variable)",
+		"usage.usage":                        "Usage: %s [options] <input text>",
+		"usage.example":                      "Example: %s --task translate \"テキスト\"",
+		"usage.init":                         "Init only: %s -init",
+		"usage.listModels":                   "List models: %s -list-models [filter]",
+		"usage.check":                        "Check settings: %s -check [-model model-name]",
+		"usage.options":                      "Options:",
+		"usage.tasks":                        "Tasks:",
+		"task.translate":                     "Japanese to English translation",
+		"task.tech-qa":                       "Answer technical questions concisely",
+		"task.summarize":                     "Summarize Japanese or English documents concisely",
+		"task.proofread":                     "Proofread English grammar and unnatural phrasing",
+		"task.explain":                       "Explain what a code snippet does concisely",
+		"task.expand":                        "Expand internal acronyms into full forms with definitions using a glossary",
+		"err.invalidLang":                    "-lang must be ja or en: %s",
+		"err.invalidCompletion":              "-completion must be bash, zsh or fish: %s",
+		"err.taskRequired":                   "Specify the task name with --task",
+		"err.invalidTask":                    "Invalid task name (-task): %s",
+		"err.inputRequired":                  "No input text was given",
+		"err.loadSettings":                   "Failed to load the settings: %v",
+		"err.setup":                          "Failed to set up the settings: %w",
+		"err.init":                           "Failed to initialize the settings: %v",
+		"status.interrupted":                 "Interrupted",
+		"init.start":                         "Initializing the settings...",
+		"init.done":                          "Settings initialized.",
+		"menu.task":                          "Select a task:",
+		"menu.choose":                        "Choose a number (1-%d): ",
+		"menu.input":                         "Enter the input text (Ctrl-D to send):",
+		"menu.invalidChoice":                 "Invalid choice: %s",
+		"setup.notFound":                     "Settings file not found. Starting interactive setup.",
+		"setup.registeredProfiles":           "Registered profiles: %s",
+		"setup.profileName":                  "Enter a profile name (default: %s): ",
+		"setup.apiMethod":                    "Select the API method to use:",
+		"setup.apiMethodKey":                 "1. Use an API key (Gemini API)",
+		"setup.apiMethodVertex":              "2. Use Vertex AI",
+		"setup.choose":                       "Choose (1 or 2): ",
+		"setup.keySource":                    "Select where to read the API key from:",
+		"setup.keySourceEnv":                 "1. Environment variable",
+		"setup.keySourceFile":                "2. File",
+		"setup.chooseDefault1":               "Choose (1 or 2, default: 1): ",
+		"setup.envVarName":                   "Enter the name of the environment variable holding the API key (default: API_KEY_GOOGLE): ",
+		"setup.keyFile":                      "Enter the path of the file containing the API key: ",
+		"setup.keyFileRequired":              "The API key file path is required",
+		"setup.invalidChoice":                "Invalid choice: %s",
+		"setup.project":                      "Enter the Google Cloud project ID: ",
+		"setup.projectRequired":              "The project ID is required",
+		"setup.location":                     "Enter the Vertex AI region (default: asia-northeast1): ",
+		"setup.makeDefault":                  "Make this profile the default? (current: %s) [y/N]: ",
+		"setup.saveFailed":                   "Failed to save the settings: %w",
+		"setup.saved":                        "Settings saved to %s.",
+		"setup.profile":                      "Profile: %s (API method: %s)",
+		"setup.defaultProfile":               "Default profile: %s",
+		"err.negativeMaxTotalTokens":         "-max-total-tokens must be 0 or greater: %d",
+		"err.negativeRetries":                "-retries must be 0 or greater: %d",
+		"err.invalidSeed":                    "-seed must be between %d and %d: %d",
+		"err.invalidMaxTokens":               "-max-tokens must be 1 or greater: %d",
+		"err.invalidBackend":                 "-backend must be apiKey or vertexAI: %s",
+		"err.invalidHistoryLimit":            "-history-limit must be 1 or greater: %d",
+		"err.negativeModelsLimit":            "-models-limit must be 0 or greater: %d",
+		"err.fileWithInput":                  "-file cannot be used together with an input text argument",
+		"err.chainWithTask":                  "-chain and -task cannot be used together",
+		"err.replConflict":                   "-repl cannot be used together with -image or -output",
+		"err.schemaConflict":                 "-schema cannot be used together with -repl or -jsonl",
+		"err.tooManyStops":                   "-stop can be specified at most %d times: %d given",
+		"err.emptyStop":                      "-stop cannot be an empty string",
+		"err.invalidCandidates":              "-candidates must be between 1 and %d: %d",
+		"err.candidatesConflict":             "-candidates cannot be used together with -repl, -schema, or -jsonl",
+		"err.pickWithoutCandidates":          "-pick can only be used when -candidates is 2 or greater",
+		"err.diffConflict":                   "-diff cannot be used together with -repl, -jsonl, -image, -output, -schema, or -candidates",
+		"err.englishOnlyTask":                "-english-only can only be used with the translate task (specified task: %s)",
+		"err.englishOnlyConflict":            "-english-only cannot be used together with -repl, -jsonl, -schema, or -candidates",
+		"err.chainConflict":                  "-chain cannot be used together with -repl, -jsonl, -image, -output, -schema, -candidates, -diff, -english-only, or -detect",
+		"err.compareConflict":                "-compare cannot be used together with -repl, -jsonl, -stdin-stream, -chain, -output, -schema, -candidates, -diff, -english-only, -count-only, -model-fallback, or -output-encoding",
+		"err.wrapConflict":                   "-wrap cannot be used together with -repl, -jsonl, -stdin-stream, -chain, -compare, -output, -schema, -diff, or -verbatim",
+		"err.stripBoldConflict":              "-strip-bold cannot be used together with -repl, -jsonl, -stdin-stream, -chain, -compare, -schema, or -verbatim",
+		"err.countOnlyConflict":              "-count-only cannot be used together with -repl, -jsonl, -stdin-stream, -chain, or -output",
+		"err.eventsConflict":                 "-events and -events-only cannot be used together with -repl, -jsonl, -stdin-stream, -chain, -compare, -count-only, -verbose, or -debug",
+		"err.invalidMetadataFormat":          "-metadata-format must be table or compact: %s",
+		"err.negativeMaxDuration":            "-max-duration must be 0 or greater: %v",
+		"err.outputEncodingConflict":         "-output-encoding cannot be used together with -repl, -jsonl, or -stdin-stream",
+		"err.invalidConcurrency":             "-concurrency must be 1 or greater: %d",
+		"err.jsonlConflict":                  "-jsonl cannot be used together with -repl, -image, -output, or -file",
+		"err.stdinStreamConflict":            "-stdin-stream cannot be used together with -repl, -jsonl, -image, -output, -file, -schema, -candidates, -diff, -english-only, or -chain",
+		"err.stdinStreamWithInput":           "-stdin-stream cannot be used together with an input text argument",
+		"warn.prefix":                        "warning: %v",
+		"warn.envFileNotFound":               "warning: env file '%s' not found",
+		"err.detectTask":                     "-detect can only be used with the translate task (specified task: %s)",
+		"status.notJapanese":                 "The input does not appear to be Japanese, so it is printed without translation (Japanese ratio: %.0f%%)",
+		"warn.thinkLevelIgnored":             "warning: model '%s' does not use -think-level (thinking is enabled with a budget of %d tokens). Use -think-budget to control the amount of thinking",
+		"warn.maxTokensBelowBudget":          "warning: -max-tokens (%d) is below the thinking budget (%d), so the output may be cut off",
+		"status.sendCancelled":               "Sending cancelled",
+		"err.negativeSettingsRetries":        "retries in the settings file must be 0 or greater: %d",
+		"err.openJSONL":                      "could not open the JSONL file: %v",
+		"status.switchAPIKey":                "API key #%d exceeded its quota; switching to API key #%d",
+		"status.retry":                       "Temporary error; retrying in %v (%d/%d): %v",
+		"status.modelFallback":               "Model '%s' not found; retrying with '%s'",
+		"status.incompleteOutput":            "[output ended prematurely: %v]",
+		"warn.englishSectionMissing":         "warning: could not extract the ENGLISH section from the output, so the whole output is shown",
+		"status.outputWritten":               "Wrote the result to %s",
+		"warn.historyRecord":                 "warning: could not record history: %v",
+		"status.moreModels":                  "... and %d more (change the number shown with -models-limit)",
+		"err.listModels":                     "failed to list models: %w",
+		"status.availableModels":             "Available models:",
+		"err.nextPage":                       "failed to fetch the next page of models: %w",
+		"err.apiKeyIndex":                    "API key index out of range: %d",
+		"err.initGeminiClient":               "failed to initialize the Gemini API client: %w",
+		"err.baseURLBackend":                 "a base URL can only be used with the Gemini API (apiKey)",
+		"err.initVertexClient":               "failed to initialize the Vertex AI client: %w",
+		"err.invalidAPIMethod":               "invalid API method: %s",
+		"err.invalidThinkLevel":              "invalid -think-level: %s (allowed: minimal|low|medium|high)",
+		"err.thinkBudgetGemini3":             "-think-budget cannot be used with Gemini 3 models. Use -think-level instead",
+		"err.negativeThinkBudget":            "-think-budget must be 0 or greater: %d",
+		"err.thinkLevelRequired":             "-think-level is required for Gemini 3 models",
+		"err.thinkLevelLowHigh":              "model '%s' only supports low or high for -think-level",
+		"err.imageUnsupported":               "task '%s' does not support image input",
+		"err.groundUnsupported":              "-ground cannot be used with task '%s'",
+		"err.schemaWithGround":               "-schema and -ground cannot be used together",
+		"err.serializeResponse":              "failed to serialize the response: %v",
+		"err.apiCall":                        "error during API call: %w",
+		"err.tokenBudgetExceeded":            "aborted: token budget exceeded (total tokens %d exceeded the limit %d)",
+		"err.maxTokensReached":               "the response was cut off because the maximum output tokens was reached (finish reason: MAX_TOKENS). Increase the maximum tokens or reduce the thinking budget",
+		"warn.maxDuration":                   "warning: the response was cut off because the time limit (-max-duration) was reached (finish reason: MAX_DURATION)",
+		"warn.unexpectedFinish":              "warning: the response ended for an unexpected reason (finish reason: %s)",
+		"err.emptyResponse":                  "the model returned an empty response (finish reason: %s)",
+		"err.invalidPick":                    "invalid -pick: %s (allowed: %s|%s|%s)",
+		"err.readAPIKeyFile":                 "failed to read API key file '%s': %w",
+		"err.emptyAPIKeyFile":                "API key file '%s' is empty",
+		"err.apiKeySourceMissing":            "no API key source (environment variable name or file) is configured",
+		"err.apiKeyEnvEmpty":                 "environment variable '%s' does not contain an API key",
+		"err.nonPositive":                    "%s must be a positive value: %d",
+		"err.apiKeyBackendNotConfigured":     "-backend apiKey was specified, but the profile has no API key source (configure it with -init)",
+		"err.vertexBackendNotConfigured":     "-backend vertexAI was specified, but the profile has no Vertex AI project or location (configure it with -init)",
+		"err.noDefaultProfile":               "no default profile is configured. Specify one with -profile",
+		"err.profileNotFound":                "profile '%s' not found (available: %s)",
+		"err.homeDir":                        "failed to get the home directory: %w",
+		"err.invalidDefaultThinkingLevel":    "invalid defaultThinkingLevel in the settings file: %s (allowed: minimal|low|medium|high)",
+		"err.negativeDefaultThinkingBudget":  "defaultThinkingBudget in the settings file must be 0 or greater: %d",
+		"err.invalidDefaultTask":             "invalid defaultTask in the settings file: %s",
+		"err.negativeConfirmOverChars":       "confirmOverChars in the settings file must be 0 or greater: %d",
+		"err.readSettings":                   "failed to read settings file '%s': %w",
+		"err.parseSettings":                  "failed to parse settings file '%s': %w",
+		"err.serializeSettings":              "failed to serialize the settings: %w",
+		"err.saveSettings":                   "failed to save the settings file: %w",
+		"err.auditLog":                       "failed to write the audit log: %v",
+		"err.createRequestID":                "could not create a request ID: %w",
+		"err.createDir":                      "could not create the directory: %w",
+		"err.lockFile":                       "could not lock the file: %w",
+		"err.readPreviousRecord":             "could not read the previous record: %w",
+		"err.serializeRecord":                "could not serialize the record: %w",
+		"status.lineError":                   "line %d: %v",
+		"err.writeResult":                    "failed to write the result: %w",
+		"err.readInput":                      "failed to read the input: %w",
+		"status.line":                        "line %d",
+		"warn.invalidRecord":                 "line %d: skipping invalid record: %v",
+		"warn.missingIDOrText":               "line %d: skipping record without id or text",
+		"status.batchTokenBudget":            "aborted: token budget exceeded (cumulative tokens %d reached the limit %d; line %d and later are not processed)",
+		"status.retryLabeled":                "%s: temporary error; retrying in %v (%d/%d): %v",
+		"err.invalidChain":                   "-chain must list two or more comma-separated tasks: %s",
+		"err.chainStage":                     "stage %d (%s): %w",
+		"err.invalidCompare":                 "-compare must list two or more comma-separated models: %s",
+		"status.compareError":                "error: %v",
+		"err.compareFailed":                  "%d models compared, %d failed",
+		"check.settingsNotFound":             "%s not found (create it with -init)",
+		"check.configured":                   "configured",
+		"check.vertexNotConfigured":          "project or location is not configured",
+		"check.modelsListed":                 "listed models (%d)",
+		"check.modelUnavailable":             "%s is not available or does not support generateContent",
+		"warn.unknownColor":                  "warning: unknown color '%s'; using %s",
+		"confirm.largeInput":                 "The input is large: about %d characters (estimated %d tokens). Send it? [y/N]: ",
+		"err.countTokens":                    "failed to count input tokens: %w",
+		"diff.original":                      "Original",
+		"diff.translation":                   "Translation",
+		"err.openEnvFile":                    "could not open env file '%s': %w",
+		"warn.envFileInvalidLine":            "warning: skipping line %[2]d of env file '%[1]s' because it is not in KEY=VALUE format",
+		"err.setEnv":                         "failed to set environment variable '%s': %w",
+		"err.readEnvFile":                    "failed to read env file '%s': %w",
+		"err.modelNotFound":                  "model '%s' was not found or does not support generateContent",
+		"err.modelNotFoundCause":             "model '%s' was not found or does not support generateContent: %v",
+		"err.contentBlocked":                 "the response was blocked (reason: %s)",
+		"err.readGlossary":                   "failed to read the glossary file: %w",
+		"err.parseGlossaryLine":              "could not parse line %[2]d of glossary file '%[1]s' (use the \"abbreviation: description\" format): %[3]s",
+		"err.emptyGlossary":                  "glossary file '%s' has no entries",
+		"err.serializeHistory":               "failed to serialize the history: %w",
+		"err.createHistoryDir":               "failed to create the history file directory: %w",
+		"err.openHistory":                    "could not open the history file: %w",
+		"err.writeHistory":                   "failed to write to the history file: %w",
+		"err.readHistory":                    "failed to read the history file: %w",
+		"history.input":                      "Input",
+		"history.output":                     "Output",
+		"status.noHistory":                   "no history (%s). Set \"saveHistory\": true in the settings file to record each run",
+		"warn.openHistory":                   "could not open the history file: %v",
+		"err.unsupportedImage":               "unsupported image format: %s (supported: png, jpg, jpeg, webp, heic, heif)",
+		"err.readImage":                      "failed to read the image file: %w",
+		"err.inputFileNotFound":              "input file '%s' not found: %w",
+		"err.inputFileIsDir":                 "input file '%s' is a directory",
+		"err.readInputFile":                  "failed to read input file '%s': %w",
+		"err.inputFile":                      "input file '%s': %w",
+		"warn.saveModelCache":                "warning: could not save the model list cache: %v",
+		"err.statOutput":                     "failed to check the output file: %w",
+		"err.outputIsDir":                    "output path '%s' is a directory",
+		"err.outputExists":                   "output file '%s' already exists. Use -force to overwrite it",
+		"err.createOutputDir":                "failed to create the output directory: %w",
+		"err.writeOutput":                    "failed to write the output file: %w",
+		"err.invalidPreflight":               "invalid -preflight: %s (allowed: %s|%s)",
+		"status.contextLimitUnknown":         "the context limit of model '%s' is unknown, so the input token count (%d) is not checked",
+		"err.contextLimitExceeded":           "input tokens (%d) exceed the context limit of model '%s' (%d)",
+		"err.contextLimitExceededWithOutput": "input tokens (%d) plus max output tokens (%d, including thinking) total %d, which exceeds the context limit of model '%s' (%d)",
+		"err.negativeRPS":                    "requests per second (-rps, requestsPerSecond) must be 0 or greater: %g",
+		"status.replStart":                   "Starting interactive mode (:reset clears the history, :exit or Ctrl-D quits)",
+		"status.replReset":                   "History cleared",
+		"err.readSchema":                     "failed to read the JSON schema file: %w",
+		"err.parseSchema":                    "failed to parse JSON schema file '%s': %w",
+		"err.schemaNotObject":                "JSON schema file '%s' is not a JSON object",
+		"err.outputNotJSON":                  "could not parse the model output as JSON:\n%s",
+		"err.formatJSON":                     "failed to format the JSON: %w",
+		"status.waiting":                     "Waiting for response...",
+		"err.serializeStats":                 "failed to serialize the stats: %w",
+		"err.createStatsDir":                 "failed to create the stats file directory: %w",
+		"err.openStats":                      "could not open the stats file: %w",
+		"err.writeStats":                     "failed to write to the stats file: %w",
+		"err.readInputText":                  "failed to read the input text: %w",
+		"err.toneUnsupported":                "-tone cannot be used with task '%s'",
+		"err.invalidTone":                    "invalid -tone: %s (allowed: %s)",
+		"err.bulletsUnsupported":             "-bullets cannot be used with task '%s'",
+		"err.glossaryUnsupported":            "-glossary cannot be used with task '%s'",
+		"err.glossaryRequired":               "task '%s' requires a glossary. Specify a glossary file with -glossary or glossaryPath in the settings file",
+		"err.invalidEncoding":                "invalid encoding: %s (allowed: utf-8|shift-jis|euc-jp)",
+		"err.decodeInput":                    "failed to convert the input encoding: %w",
+		"err.encodeOutput":                   "failed to convert the output encoding: %w",
+		"err.invalidWrap":                    "-wrap must be a column count of 1 or greater, or %s: %s",
+	},
+}
+
+// キーに対応するメッセージを現在の言語で返す
+func msg(key string) string {
+	if m, ok := messageCatalog[uiLang][key]; ok {
+		return m
+	}
+	if m, ok := messageCatalog["ja"][key]; ok {
+		return m
+	}
+	return key
+}
+
+// キーに対応するメッセージを書式として値を埋め込んで返す
+func msgf(key string, args ...any) string {
+	return fmt.Sprintf(msg(key), args...)
+}
+
+// キーに対応するメッセージを書式としてエラーを返す (%wでエラーをラップできる)
+func errorf(key string, args ...any) error {
+	return fmt.Errorf(msg(key), args...)
+}
+
+// 対応している言語かを判定する
+func isSupportedUILang(lang string) bool {
+	_, ok := messageCatalog[lang]
+	return ok
+}
+
+// CLIのメッセージの言語を決める
+// フラグの定義 (ヘルプの文言) より前に言語を決める必要があるため、引数から -lang を先に探す
+// -lang の指定を優先し、なければ環境変数、どちらもなければ日本語を使う
+func detectUILang(args []string) string {
+	lang := strings.ToLower(strings.TrimSpace(os.Getenv(uiLangEnvVar)))
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "lang" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		lang = strings.ToLower(strings.TrimSpace(value))
+	}
+	if !isSupportedUILang(lang) {
+		return "ja"
+	}
+	return lang
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// メタデータの表示形式
+const (
+	metadataFormatTable   = "table"   // 項目ごとに1行の表 (デフォルト)
+	metadataFormatCompact = "compact" // 1行にまとめた形式
+)
+
+// メタデータの表示形式 (-metadata-format)
+var metadataFormat = metadataFormatTable
+
+// メタデータの表示項目
+type metadataRow struct {
+	Label string
+	Value string
+}
+
+// メタデータを表示する項目の一覧に変換する
+func metadataRows(metadata LLMMetadata, apiMethod string, taskName string) []metadataRow {
+	rows := []metadataRow{
+		{"Task", taskName},
+		{"API method", apiMethod},
+	}
+	if metadata.APIVersion != "" {
+		rows = append(rows, metadataRow{"API version", metadata.APIVersion})
+	}
+	if metadata.APIKeyCount > 1 {
+		rows = append(rows, metadataRow{"API key", fmt.Sprintf("#%d of %d", metadata.APIKeyIndex+1, metadata.APIKeyCount)})
+	}
+	finishReason := metadata.FinishReason
+	if finishReason == "" {
+		finishReason = "(unknown)"
+	}
+	throughput := "(n/a)"
+	if tokensPerSecond, ok := metadata.outputTokensPerSecond(); ok {
+		throughput = fmt.Sprintf("%.1f tokens/s", tokensPerSecond)
+	}
+	rows = append(rows,
+		metadataRow{"API call time", metadata.APICallTime.String()},
+		metadataRow{"Time to first token", metadata.TimeToFirstToken.String()},
+		metadataRow{"Model", metadata.Model},
+		metadataRow{"Model version", metadata.ModelVersion},
+		metadataRow{"Finish reason", finishReason},
+		metadataRow{"Prompt token count", fmt.Sprint(metadata.PromptTokenCount)},
+		metadataRow{"Candidate token count", fmt.Sprint(metadata.CandidatesTokenCount)},
+		metadataRow{"Thoughts token count", fmt.Sprint(metadata.ThoughtsTokenCount)},
+		metadataRow{"Total token count", fmt.Sprint(metadata.TotalTokenCount)},
+		metadataRow{"Output throughput", throughput},
+		metadataRow{"Output characters", fmt.Sprint(metadata.OutputCharCount)},
+		metadataRow{"Output words", fmt.Sprint(metadata.OutputWordCount)},
+	)
+	if metadata.PickedCandidate > 0 {
+		rows = append(rows, metadataRow{"Picked candidate", fmt.Sprint(metadata.PickedCandidate)})
+	}
+	if metadata.Seed != nil {
+		rows = append(rows, metadataRow{"Seed", fmt.Sprint(*metadata.Seed)})
+	}
+	if metadata.PreflightTokenCount > 0 {
+		rows = append(rows, metadataRow{"Preflight input tokens", fmt.Sprint(metadata.PreflightTokenCount)})
+	}
+	if metadata.Grounding {
+		sources := "(none)"
+		if len(metadata.GroundingSources) > 0 {
+			sources = fmt.Sprint(len(metadata.GroundingSources))
+		}
+		rows = append(rows, metadataRow{"Grounding sources", sources})
+	}
+	return rows
+}
+
+// メタデータを標準エラー出力に表示する
+func printMetadata(metadata LLMMetadata, apiMethod string, taskName string) {
+	writeMetadata(os.Stderr, metadata, apiMethod, taskName)
+}
+
+// メタデータを-metadata-formatの形式で書き込む
+// tableでは値の長さに関わらずラベルと値の列を揃え、グラウンディングの参照元は表の後に一覧で表示する
+func writeMetadata(w io.Writer, metadata LLMMetadata, apiMethod string, taskName string) {
+	rows := metadataRows(metadata, apiMethod, taskName)
+	if metadataFormat == metadataFormatCompact {
+		fields := make([]string, len(rows))
+		for i, row := range rows {
+			fields[i] = row.Label + ": " + row.Value
+		}
+		fmt.Fprintln(w, "✓ "+strings.Join(fields, " | "))
+		return
+	}
+
+	fmt.Fprintln(w, "==== Metadata ====")
+	table := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(table, "✓ %s:\t%s\n", row.Label, row.Value)
+	}
+	table.Flush()
+	for _, source := range metadata.GroundingSources {
+		fmt.Fprintf(w, "    - %s %s\n", source.Title, source.URI)
+	}
+	fmt.Fprintln(w, "==================")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// モデル一覧のキャッシュの有効期間
+const modelCacheTTL = 24 * time.Hour
+
+// -refresh-modelsフラグが指定された場合はキャッシュを使わずにモデル一覧を取得し直す
+var refreshModelCache bool
+
+// ディスクに保存するモデル一覧のキャッシュ
+// 接続先 (バックエンド、プロジェクト、ロケーション、ベースURL) が異なる場合は使わない
+type modelCache struct {
+	Key       string         `json:"key"`
+	FetchedAt time.Time      `json:"fetchedAt"`
+	Models    []*genai.Model `json:"models"`
+}
+
+// モデル一覧のキャッシュファイルのパスを返す (設定ファイルと同じディレクトリに置く)
+func getModelCachePath() (string, error) {
+	settingsPath, err := getSettingsPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(settingsPath), "models-cache.json"), nil
+}
+
+// クライアントの接続先を表すキャッシュのキーを返す
+func modelCacheKey(client *genai.Client) string {
+	config := client.ClientConfig()
+	return fmt.Sprintf("%s|%s|%s|%s", config.Backend, config.Project, config.Location, config.HTTPOptions.BaseURL)
+}
+
+// キャッシュファイルを読み込む
+func readModelCache() (*modelCache, error) {
+	cachePath, err := getModelCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	var cache modelCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// 有効期間内のキャッシュがあればモデル一覧を返す
+func loadModelCache(key string) ([]*genai.Model, bool) {
+	cache, err := readModelCache()
+	if err != nil {
+		return nil, false
+	}
+	if cache.Key != key || time.Since(cache.FetchedAt) > modelCacheTTL {
+		return nil, false
+	}
+	return cache.Models, true
+}
+
+// キャッシュにあるモデル名 (models/ を除いたもの) を返す
+// 補完候補に使うため、接続先や有効期間は確認しない
+func cachedModelNames() []string {
+	cache, err := readModelCache()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(cache.Models))
+	for _, m := range cache.Models {
+		names = append(names, shortModelName(m.Name))
+	}
+	return names
+}
+
+// モデル一覧をキャッシュファイルに保存する
+func saveModelCache(key string, models []*genai.Model) error {
+	cachePath, err := getModelCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(modelCache{Key: key, FetchedAt: time.Now(), Models: models})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, data, 0644)
+}
+
+// generateContentに対応するモデル一覧を返す
+// 有効期間内のキャッシュがあればAPIを呼び出さずにそれを使い、取得し直した場合はキャッシュを更新する
+func cachedAvailableModels(ctx context.Context, client *genai.Client) ([]*genai.Model, error) {
+	key := modelCacheKey(client)
+	if !refreshModelCache {
+		if models, ok := loadModelCache(key); ok {
+			return models, nil
+		}
+	}
+
+	models, err := fetchAvailableModels(ctx, client)
+	if err != nil {
+		return models, err
+	}
+	if err := saveModelCache(key, models); err != nil {
+		printNotice(msgf("warn.saveModelCache", err))
+	}
+	return models, nil
+}
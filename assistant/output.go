@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// 出力先のパイプが閉じられた (読み手が終了した) ことによる書き込みエラーかを判定する
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed)
+}
+
+// -outputで指定されたファイルに書き込めるかを確認する
+// ファイルが既に存在する場合はforceがtrueでなければエラーを返す
+func checkOutputPath(path string, force bool) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errorf("err.statOutput", err)
+	}
+	if info.IsDir() {
+		return errorf("err.outputIsDir", path)
+	}
+	if !force {
+		return errorf("err.outputExists", path)
+	}
+	return nil
+}
+
+// 結果をファイルに書き込む (親ディレクトリがなければ作成する)
+func writeOutputFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errorf("err.createOutputDir", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errorf("err.writeOutput", err)
+	}
+	return nil
+}
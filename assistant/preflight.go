@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// トークン数をカウントするクライアントのインターフェース
+// *genai.Models がこれを満たす
+type tokenCounter interface {
+	CountTokens(ctx context.Context, model string, contents []*genai.Content, config *genai.CountTokensConfig) (*genai.CountTokensResponse, error)
+}
+
+// -preflight で指定できるモード
+const (
+	preflightWarn  = "warn"
+	preflightAbort = "abort"
+)
+
+// モデル名のプレフィックスと入力コンテキストの上限トークン数
+// 前方一致で先に見つかったものを使うため、より具体的なプレフィックスを先に書く
+var modelContextLimits = []struct {
+	Prefix string
+	Limit  int32
+}{
+	{Prefix: "gemini-3", Limit: 1048576},
+	{Prefix: "gemini-2.5", Limit: 1048576},
+	{Prefix: "gemini-2.0", Limit: 1048576},
+	{Prefix: "gemini-1.5-pro", Limit: 2097152},
+	{Prefix: "gemini-1.5-flash", Limit: 1048576},
+}
+
+// モデルの入力コンテキストの上限トークン数を返す
+// 不明なモデルの場合はfalseを返す
+func contextLimitForModel(modelName string) (int32, bool) {
+	name := strings.ToLower(strings.TrimSpace(modelName))
+	name = strings.TrimPrefix(name, "models/")
+	for _, entry := range modelContextLimits {
+		if strings.HasPrefix(name, entry.Prefix) {
+			return entry.Limit, true
+		}
+	}
+	return 0, false
+}
+
+// -preflight の値を検証する
+func validatePreflightMode(mode string) error {
+	switch mode {
+	case "", preflightWarn, preflightAbort:
+		return nil
+	default:
+		return errorf("err.invalidPreflight", mode, preflightWarn, preflightAbort)
+	}
+}
+
+// 送信前に入力のトークン数をカウントし、最大出力トークン数 (思考を含む) を加えた合計をモデルのコンテキスト上限と比較する
+// 上限を超えている場合、modeがabortならエラーを返し、warnなら警告を表示する
+func preflightTokenCount(ctx context.Context, counter tokenCounter, llmReqConfig LlmRequestConfig, mode string) (int32, error) {
+	resp, err := counter.CountTokens(ctx, llmReqConfig.Model, buildContents(llmReqConfig), nil)
+	if err != nil {
+		return 0, errorf("err.countTokens", err)
+	}
+
+	limit, ok := contextLimitForModel(llmReqConfig.Model)
+	if !ok {
+		printNotice(msgf("status.contextLimitUnknown", llmReqConfig.Model, resp.TotalTokens))
+		return resp.TotalTokens, nil
+	}
+
+	var limitErr error
+	switch required := resp.TotalTokens + llmReqConfig.MaxTokens; {
+	case resp.TotalTokens > limit:
+		limitErr = errorf("err.contextLimitExceeded", resp.TotalTokens, llmReqConfig.Model, limit)
+	case required > limit:
+		limitErr = errorf("err.contextLimitExceededWithOutput", resp.TotalTokens, llmReqConfig.MaxTokens, required, llmReqConfig.Model, limit)
+	}
+	if limitErr != nil {
+		if mode == preflightAbort {
+			return resp.TotalTokens, limitErr
+		}
+		printNotice(msgf("warn.prefix", limitErr))
+	}
+
+	return resp.TotalTokens, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// 生成リクエストの送信間隔を制限するレートリミッタ (nilの場合は制限しない)
+// -jsonl の並行処理でも共有し、リクエストの間隔をならす
+var requestLimiter *rate.Limiter
+
+// 1秒あたりの最大リクエスト数を決定する
+// フラグの値 (0以外) を優先し、次に設定ファイルの値を使う。どちらもなければ0 (制限なし)
+func resolveRequestsPerSecond(flagRPS float64, settings *Settings) (float64, error) {
+	rps := flagRPS
+	if rps == 0 && settings != nil {
+		rps = settings.RequestsPerSecond
+	}
+	if rps < 0 {
+		return 0, errorf("err.negativeRPS", rps)
+	}
+	return rps, nil
+}
+
+// 1秒あたりrps回までリクエストを送信できるレートリミッタを作成する (rpsが0の場合はnil)
+// バーストは1とし、連続したリクエストも1/rps秒ずつ間隔を空ける
+func newRequestLimiter(rps float64) *rate.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(rps), 1)
+}
+
+// レートリミッタが設定されている場合は、次のリクエストを送信できるまで待つ
+func waitRequestLimiter(ctx context.Context) error {
+	if requestLimiter == nil {
+		return nil
+	}
+	return requestLimiter.Wait(ctx)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"google.golang.org/genai"
+)
+
+// 対話モードでセッション全体のトークン使用量を集計する
+type sessionUsage struct {
+	Turns                int
+	PromptTokenCount     int32
+	CandidatesTokenCount int32
+	ThoughtsTokenCount   int32
+	TotalTokenCount      int32
+}
+
+func (u *sessionUsage) add(metadata LLMMetadata) {
+	u.Turns++
+	u.PromptTokenCount += metadata.PromptTokenCount
+	u.CandidatesTokenCount += metadata.CandidatesTokenCount
+	u.ThoughtsTokenCount += metadata.ThoughtsTokenCount
+	u.TotalTokenCount += metadata.TotalTokenCount
+}
+
+// セッション全体のトークン使用量を出力
+func (u *sessionUsage) print() {
+	fmt.Fprintln(os.Stderr, "==== Session total ====")
+	fmt.Fprintln(os.Stderr, "✓ Turns:                 ", u.Turns)
+	fmt.Fprintln(os.Stderr, "✓ Prompt token count:    ", u.PromptTokenCount)
+	fmt.Fprintln(os.Stderr, "✓ Candidate token count: ", u.CandidatesTokenCount)
+	fmt.Fprintln(os.Stderr, "✓ Thoughts token count:  ", u.ThoughtsTokenCount)
+	fmt.Fprintln(os.Stderr, "✓ Total token count:     ", u.TotalTokenCount)
+	fmt.Fprintln(os.Stderr, "=======================")
+}
+
+// 対話モードを実行する
+// 各ターンの入力と回答を履歴として保持し、次のリクエストで履歴全体を送信する
+// firstInputが空でなければ最初のターンの入力として使う
+// ":reset" で履歴を消去し、":exit" / ":quit" または EOF で終了する
+func runREPL(ctx context.Context, client *genai.Client, apiMethod string, task TaskDefinition, reqOpts requestOptions, charsPerStep int, delay time.Duration, thoughtColor *color.Color, showSpinner bool, firstInput string) error {
+	var history []*genai.Content
+	var usage sessionUsage
+	defer usage.print()
+
+	fmt.Fprintln(os.Stderr, msg("status.replStart"))
+
+	// 入力待ちの間もCtrl-Cで終了できるよう、標準入力は別のgoroutineで読み込む
+	lines := make(chan string)
+	scanner := bufio.NewScanner(os.Stdin)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	pending := strings.TrimSpace(firstInput)
+	for {
+		input := pending
+		pending = ""
+		if input == "" {
+			fmt.Fprint(os.Stderr, "> ")
+			select {
+			case <-ctx.Done():
+				fmt.Fprintln(os.Stderr)
+				return ctx.Err()
+			case line, ok := <-lines:
+				if !ok {
+					fmt.Fprintln(os.Stderr)
+					return scanner.Err()
+				}
+				input = strings.TrimSpace(line)
+			}
+		}
+
+		switch input {
+		case "":
+			continue
+		case ":exit", ":quit":
+			return nil
+		case ":reset":
+			history = nil
+			fmt.Fprintln(os.Stderr, msg("status.replReset"))
+			continue
+		}
+
+		llmReqConfig, genaiConfig, err := createLLMConfigs(task, input, nil, reqOpts)
+		if err != nil {
+			return err
+		}
+		llmReqConfig.History = history
+
+		// 回答を表示しつつ、履歴に追加するために回答テキストを保持する
+		var answer strings.Builder
+		output := newTypewriter(os.Stdout, charsPerStep, delay)
+		var out, thoughtOut io.Writer = io.MultiWriter(output, &answer), colorWriter{output, thoughtColor}
+		var spin *spinner
+		if showSpinner {
+			spin = startSpinner()
+			out, thoughtOut = spinnerStopWriter{out, spin}, spinnerStopWriter{thoughtOut, spin}
+		}
+		metadata, err := streamContent(ctx, client.Models, llmReqConfig, genaiConfig, out, thoughtOut)
+		spin.Stop()
+		output.Close()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 監査ログへの記録に失敗した場合は対話を続けずに終了する
+		if isAuditLogError(err) {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+
+		history = append(buildContents(llmReqConfig), genai.NewContentFromText(answer.String(), genai.RoleModel))
+		usage.add(metadata)
+		printMetadata(metadata, apiMethod, task.Name)
+		fmt.Fprintf(os.Stderr, "✓ Session total tokens:   %d (%d turns)\n", usage.TotalTokenCount, usage.Turns)
+	}
+}
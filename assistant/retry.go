@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// 再試行の最大回数のデフォルト値
+const defaultRetries = 3
+
+// 再試行前の初回の待機時間 (再試行ごとに倍にする)
+const retryBaseDelay = 2 * time.Second
+
+// 再試行で回復する可能性のある一時的なエラー (クォータ超過、サーバーの一時的な障害) かを判定する
+// 監査ログへの記録に失敗した場合は再試行しない
+func isTransientError(err error) bool {
+	if isAuditLogError(err) {
+		return false
+	}
+	if isQuotaError(err) {
+		return true
+	}
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == 500 || apiErr.Code == 503 || apiErr.Status == "UNAVAILABLE"
+}
+
+// attempt回目 (1始まり) の再試行前の待機時間を返す
+func retryDelay(attempt int) time.Duration {
+	return retryBaseDelay << (attempt - 1)
+}
+
+// 再試行前に待機する。待機中にキャンセルされた場合はctxのエラーを返す
+func waitRetry(ctx context.Context, delay time.Duration) error {
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 再試行の最大回数を解決する
+// フラグの指定を優先し、次に設定ファイルの値、どちらもなければデフォルト値を使う
+func resolveRetries(flagRetries *int, settings *Settings) int {
+	if flagRetries != nil {
+		return *flagRetries
+	}
+	if settings != nil && settings.Retries != nil {
+		return *settings.Retries
+	}
+	return defaultRetries
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// 構造化出力のMIMEタイプ
+const jsonMIMEType = "application/json"
+
+// -schemaで指定されたJSONスキーマファイルを読み込む
+func loadResponseSchema(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorf("err.readSchema", err)
+	}
+	var schema any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, errorf("err.parseSchema", path, err)
+	}
+	if _, ok := schema.(map[string]any); !ok {
+		return nil, errorf("err.schemaNotObject", path)
+	}
+	return schema, nil
+}
+
+// モデルの出力がJSONとして解析できるかを確認し、インデントを整えて返す
+func formatJSONOutput(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return nil, errorf("err.outputNotJSON", data)
+	}
+	var formatted bytes.Buffer
+	if err := json.Indent(&formatted, data, "", "  "); err != nil {
+		return nil, errorf("err.formatJSON", err)
+	}
+	formatted.WriteByte('\n')
+	return formatted.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// 最初のトークンが届くまで標準エラー出力に表示するスピナー
+// nilのスピナーに対するメソッド呼び出しは何もしない
+type spinner struct {
+	out      io.Writer
+	stopChan chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// ファイルが端末 (TTY) かどうかを判定する
+// /dev/null などのキャラクタデバイスは端末として扱わない
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// 標準エラー出力が端末の場合のみスピナーを開始する
+// 端末でない場合はnilを返す
+func startSpinner() *spinner {
+	if !isTerminal(os.Stderr) {
+		return nil
+	}
+	s := &spinner{
+		out:      os.Stderr,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *spinner) run() {
+	defer close(s.done)
+
+	frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		fmt.Fprintf(s.out, "\r%s %s", frames[i%len(frames)], msg("status.waiting"))
+		select {
+		case <-s.stopChan:
+			// スピナーの表示を消去してカーソルを行頭に戻す
+			fmt.Fprint(s.out, "\r\033[K")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// スピナーを停止して表示を消去する (複数回呼び出しても安全)
+func (s *spinner) Stop() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		close(s.stopChan)
+		<-s.done
+	})
+}
+
+// 最初の書き込みの前にスピナーを停止するio.Writer
+type spinnerStopWriter struct {
+	w       io.Writer
+	spinner *spinner
+}
+
+func (w spinnerStopWriter) Write(p []byte) (int, error) {
+	w.spinner.Stop()
+	return w.w.Write(p)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// -stats-json で追記する1回の実行の記録
+type statsRecord struct {
+	Timestamp              time.Time `json:"timestamp"`
+	Task                   string    `json:"task"`
+	Model                  string    `json:"model"`
+	ModelVersion           string    `json:"modelVersion"`
+	APIMethod              string    `json:"apiMethod"`
+	PromptTokenCount       int32     `json:"promptTokenCount"`
+	CandidatesTokenCount   int32     `json:"candidatesTokenCount"`
+	ThoughtsTokenCount     int32     `json:"thoughtsTokenCount"`
+	TotalTokenCount        int32     `json:"totalTokenCount"`
+	APICallTimeMillis      int64     `json:"apiCallTimeMillis"`
+	TimeToFirstTokenMillis int64     `json:"timeToFirstTokenMillis"`
+}
+
+// メタデータから1回の実行の記録を作成する
+func newStatsRecord(metadata LLMMetadata, apiMethod string, taskName string, modelName string) statsRecord {
+	return statsRecord{
+		Timestamp:              time.Now(),
+		Task:                   taskName,
+		Model:                  modelName,
+		ModelVersion:           metadata.ModelVersion,
+		APIMethod:              apiMethod,
+		PromptTokenCount:       metadata.PromptTokenCount,
+		CandidatesTokenCount:   metadata.CandidatesTokenCount,
+		ThoughtsTokenCount:     metadata.ThoughtsTokenCount,
+		TotalTokenCount:        metadata.TotalTokenCount,
+		APICallTimeMillis:      metadata.APICallTime.Milliseconds(),
+		TimeToFirstTokenMillis: metadata.TimeToFirstToken.Milliseconds(),
+	}
+}
+
+// メタデータを1行のJSONとしてファイルに追記する
+// 1回のwriteでO_APPENDのファイルに書き込むため、並行して実行されても行が混ざりにくい
+func appendStatsJSON(path string, metadata LLMMetadata, apiMethod string, taskName string, modelName string) error {
+	line, err := json.Marshal(newStatsRecord(metadata, apiMethod, taskName, modelName))
+	if err != nil {
+		return errorf("err.serializeStats", err)
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errorf("err.createStatsDir", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errorf("err.openStats", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return errorf("err.writeStats", err)
+	}
+	return nil
+}
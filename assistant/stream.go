@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 標準入力を1行ずつ読み込み、空でない行を読み込むたびに処理して結果を出力する (-stdin-stream)
+// 増え続けるログなどを入力が閉じられるまで順に処理し、各行の結果はその行の処理が終わった時点で書き込む
+// translateタスクではENGLISHセクションのみを出力する
+// API呼び出しに失敗した行は行番号とともに標準エラー出力に報告して続行する (監査ログへの記録に失敗した場合は中止する)
+func runStdinStream(ctx context.Context, streamer contentStreamer, r io.Reader, w io.Writer, task TaskDefinition, reqOpts requestOptions, retries int) (batchSummary, error) {
+	var summary batchSummary
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		llmReqConfig, genaiConfig, err := createLLMConfigs(task, line, nil, reqOpts)
+		if err != nil {
+			return summary, err
+		}
+		text, metadata, err := streamContentWithBackoff(ctx, streamer, llmReqConfig, genaiConfig, msgf("status.line", lineNumber), retries)
+		summary.Processed++
+		summary.TotalTokens += metadata.TotalTokenCount
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+		if err != nil {
+			summary.Failed++
+			if isAuditLogError(err) {
+				return summary, err
+			}
+			fmt.Fprintln(os.Stderr, msgf("status.lineError", lineNumber, err))
+			continue
+		}
+
+		// 行ごとの結果をすぐに読み手に届けるため、バッファを介さずに書き込む
+		if _, err := fmt.Fprintln(w, stageOutputForNext(task, text)); err != nil {
+			return summary, errorf("err.writeResult", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, errorf("err.readInput", err)
+	}
+	return summary, nil
+}
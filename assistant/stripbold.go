@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Markdownの太字 (**...**) に一致する正規表現
+// 前後が空白の ** (例: 2 ** 3) は太字として扱わない
+var boldPattern = regexp.MustCompile(`\*\*([^\s*](?:[^*]*?[^\s*])?)\*\*`)
+
+// 書き込まれたテキストからMarkdownの太字の ** を取り除いて出力するio.Writer
+// 太字の開始と終了を対応させるため行単位で出力する
+// コードブロック (``` または ~~~ で囲まれた範囲)、4桁以上のインデントやタブで始まる行、インラインコード (`...`) の中はそのまま出力する
+// 最後にFlushを呼び出して、最後の行を出力すること
+type boldStripWriter struct {
+	out     io.Writer
+	line    strings.Builder // 改行が来るまで溜めている行
+	inFence bool            // コードブロックの中か
+}
+
+// outに太字を取り除いたテキストを出力するboldStripWriterを作成する
+func newBoldStripWriter(out io.Writer) *boldStripWriter {
+	return &boldStripWriter{out: out}
+}
+
+func (w *boldStripWriter) Write(p []byte) (int, error) {
+	var buf strings.Builder
+	for _, b := range p {
+		w.line.WriteByte(b)
+		if b == '\n' {
+			buf.WriteString(w.stripLine(w.line.String()))
+			w.line.Reset()
+		}
+	}
+	if buf.Len() > 0 {
+		if _, err := io.WriteString(w.out, buf.String()); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// 溜めている最後の行 (改行で終わっていない行) を出力する
+func (w *boldStripWriter) Flush() error {
+	if w.line.Len() == 0 {
+		return nil
+	}
+	line := w.stripLine(w.line.String())
+	w.line.Reset()
+	_, err := io.WriteString(w.out, line)
+	return err
+}
+
+// 1行からコードを除いた部分の太字を取り除く
+func (w *boldStripWriter) stripLine(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		w.inFence = !w.inFence
+		return line
+	}
+	if w.inFence || strings.HasPrefix(line, "    ") || strings.HasPrefix(trimmed, "\t") {
+		return line
+	}
+
+	// インラインコード (同じ数のバッククォートで囲まれた範囲) の外側のみを置き換える
+	var result strings.Builder
+	rest := line
+	for {
+		start := strings.Index(rest, "`")
+		if start < 0 {
+			break
+		}
+		ticks := len(rest[start:]) - len(strings.TrimLeft(rest[start:], "`"))
+		end := closingBackticks(rest[start+ticks:], ticks)
+		if end < 0 {
+			break
+		}
+		end += start + ticks + ticks
+		result.WriteString(boldPattern.ReplaceAllString(rest[:start], "$1"))
+		result.WriteString(rest[start:end])
+		rest = rest[end:]
+	}
+	result.WriteString(boldPattern.ReplaceAllString(rest, "$1"))
+	return result.String()
+}
+
+// textの中から、ちょうどticks個のバッククォートの並びの位置を返す (見つからない場合は-1)
+func closingBackticks(text string, ticks int) int {
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(text) && text[j] == '`' {
+			j++
+		}
+		if j-i == ticks {
+			return i
+		}
+		i = j
+	}
+	return -1
+}
+
+// テキストからMarkdownの太字の ** を取り除く (コードの中は除く)
+func stripBold(text string) string {
+	var b strings.Builder
+	w := newBoldStripWriter(&b)
+	w.Write([]byte(text))
+	w.Flush()
+	return b.String()
+}
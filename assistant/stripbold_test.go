@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// chunksを順に書き込んでFlushし、太字を取り除いた結果を返す
+func stripBoldChunks(t *testing.T, chunks ...string) string {
+	t.Helper()
+	var out strings.Builder
+	w := newBoldStripWriter(&out)
+	for _, chunk := range chunks {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	return out.String()
+}
+
+func TestBoldStripWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"bold", "**太字** と **bold text**\n", "太字 と bold text\n"},
+		{"last line without newline", "a **b** c", "a b c"},
+		{"unmatched trailing asterisk", "a **b** and *\n", "a b and *\n"},
+		{"unmatched opening", "a **b* c\n", "a **b* c\n"},
+		{"italic", "*italic* stays\n", "*italic* stays\n"},
+		{"operator with spaces", "2 ** 3 = 8\n", "2 ** 3 = 8\n"},
+		{"inline code", "use `**kwargs` and **bold**\n", "use `**kwargs` and bold\n"},
+		{"double backtick code", "``a ` **b**`` **c**\n", "``a ` **b**`` c\n"},
+		{"unclosed backtick", "`**a** b\n", "`a b\n"},
+		{"fenced code", "```\n**x**\n```\n**y**\n", "```\n**x**\n```\ny\n"},
+		{"indented code", "    **x**\n**y**\n", "    **x**\ny\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripBoldChunks(t, tt.input); got != tt.want {
+				t.Errorf("stripBold(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoldStripWriterSplitChunks(t *testing.T) {
+	// ** が2回の書き込みに分かれても取り除く
+	if got := stripBoldChunks(t, "this is *", "*bold** text\n"); got != "this is bold text\n" {
+		t.Errorf("split opening: got %q", got)
+	}
+	if got := stripBoldChunks(t, "this is **bold*", "* text\n"); got != "this is bold text\n" {
+		t.Errorf("split closing: got %q", got)
+	}
+
+	// どのバイト位置で分割しても、まとめて書き込んだ場合と同じ結果になる
+	input := "**太字** と `**code**`\n```\n**fenced**\n```\n最後の **行**"
+	want := stripBoldChunks(t, input)
+	for i := 1; i < len(input); i++ {
+		if got := stripBoldChunks(t, input[:i], input[i:]); got != want {
+			t.Errorf("split at %d: got %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// タスクの番号付きメニューを表示して選択させる
+// 入力テキストが指定されていない場合 (needInput) は、続けて入力テキストをEOFまで読み込む
+// メニューと入力の案内は標準出力の結果と混ざらないようoutに書き込む
+func selectTaskInteractive(in io.Reader, out io.Writer, needInput bool) (TaskDefinition, string, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	fmt.Fprintln(out, msg("menu.task"))
+	for i, task := range taskDefinitions {
+		fmt.Fprintf(out, "%d. %s: %s\n", i+1, task.Name, task.localizedDescription())
+	}
+	fmt.Fprint(out, msgf("menu.choose", len(taskDefinitions)))
+	scanner.Scan()
+	choice := strings.TrimSpace(scanner.Text())
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(taskDefinitions) {
+		return TaskDefinition{}, "", errorf("menu.invalidChoice", choice)
+	}
+	task := taskDefinitions[index-1]
+
+	if !needInput {
+		return task, "", nil
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, msg("menu.input"))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return TaskDefinition{}, "", errorf("err.readInputText", err)
+	}
+	return task, strings.Join(lines, "\n"), nil
+}
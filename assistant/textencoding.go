@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// 入出力の文字コードとして指定できる名前 (-input-encoding、-output-encoding) と対応するエンコーディング
+// UTF-8はnil (変換しない) で表す
+var textEncodings = map[string]encoding.Encoding{
+	"utf-8":     nil,
+	"shift-jis": japanese.ShiftJIS,
+	"euc-jp":    japanese.EUCJP,
+}
+
+// 文字コードの別名
+var textEncodingAliases = map[string]string{
+	"utf8":      "utf-8",
+	"sjis":      "shift-jis",
+	"shift_jis": "shift-jis",
+	"shiftjis":  "shift-jis",
+	"cp932":     "shift-jis",
+	"eucjp":     "euc-jp",
+	"euc_jp":    "euc-jp",
+}
+
+// 文字コードの名前からエンコーディングを返す (UTF-8または空の場合はnil)
+func lookupTextEncoding(name string) (encoding.Encoding, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" {
+		return nil, nil
+	}
+	if alias, ok := textEncodingAliases[normalized]; ok {
+		normalized = alias
+	}
+	enc, ok := textEncodings[normalized]
+	if !ok {
+		return nil, errorf("err.invalidEncoding", name)
+	}
+	return enc, nil
+}
+
+// 指定した文字コードのテキストをUTF-8に変換する (encがnilの場合はそのまま返す)
+func decodeText(data []byte, enc encoding.Encoding) ([]byte, error) {
+	if enc == nil {
+		return data, nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, errorf("err.decodeInput", err)
+	}
+	return decoded, nil
+}
+
+// UTF-8のテキストを指定した文字コードに変換して書き込むWriterを返す
+// 変換できない文字は文字コードの代替文字に置き換える
+// 書き込みの途中で分割された文字は次の書き込みまで保持するため、最後に必ずCloseを呼び出すこと
+func newEncodingWriter(w io.Writer, enc encoding.Encoding) io.WriteCloser {
+	return transform.NewWriter(w, encoding.ReplaceUnsupported(enc.NewEncoder()))
+}
+
+// UTF-8のテキストを指定した文字コードに変換する (encがnilの場合はそのまま返す)
+// 変換できない文字は文字コードの代替文字に置き換える
+func encodeText(data []byte, enc encoding.Encoding) ([]byte, error) {
+	if enc == nil {
+		return data, nil
+	}
+	encoded, err := encoding.ReplaceUnsupported(enc.NewEncoder()).Bytes(data)
+	if err != nil {
+		return nil, errorf("err.encodeOutput", err)
+	}
+	return encoded, nil
+}
@@ -2,61 +2,116 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"html"
+	"io"
+	"iter"
 	"log"
 	"os"
 	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
-	"github.com/fatih/color"
 	"google.golang.org/genai"
 )
 
 // システム指示、モデル、入力テキストなどのLLMリクエスト設定
 type LlmRequestConfig struct {
 	SystemInstruction string
+	SystemPrefix      string // システム指示の先頭に加えたチーム共通などの指示 (空の場合は加えていない)
 	Model             string
 	MaxTokens         int32
 	InputText         string
+	Image             *imageInput
+	History           []*genai.Content // 対話モードでこれまでにやり取りした内容
 	IncludeThoughts   bool
 	ThinkingBudget    *int32
 	ThinkingLevel     genai.ThinkingLevel
+	Grounding         bool
+	MaxTotalTokens    int32         // 合計トークン数の上限 (0の場合は無制限)
+	ResponseSchema    any           // 構造化出力のJSONスキーマ (nilの場合はテキストで出力)
+	CandidateCount    int32         // 生成する候補の数 (0または1の場合は1つ)
+	MaxDuration       time.Duration // 1回のリクエストの経過時間の上限 (0の場合は無制限)
+	Seed              *int32        // 生成の乱数シード (nilの場合は指定しない)
+	StopSequences     []string      // 生成を打ち切る文字列
+	Pick              string        // 複数の候補から1つを選んで出力する方法 (空の場合はすべて出力)
+	TaskName          string
 }
 
 // LLMリクエストに関するメタデータ
 type LLMMetadata struct {
+	Model                string // リクエストを処理したモデル (フォールバックした場合はフォールバック先)
 	APICallTime          time.Duration
+	TimeToFirstToken     time.Duration // 最初のテキスト (思考プロセスを含む) を受け取るまでの時間
 	ModelVersion         string
+	FinishReason         string
 	PromptTokenCount     int32
 	CandidatesTokenCount int32
 	ThoughtsTokenCount   int32
 	TotalTokenCount      int32
+	PreflightTokenCount  int32
+	OutputCharCount      int
+	OutputWordCount      int
+	APIKeyIndex          int    // 使用したAPIキーのインデックス (0が主キー)
+	APIKeyCount          int    // 設定されているAPIキーの数 (APIキーを使わない場合は0)
+	APIVersion           string // リクエストに使ったAPIのバージョン
+	Grounding            bool
+	GroundingSources     []GroundingSource
+	Seed                 *int32 // リクエストに指定した乱数シード (nilの場合は指定なし)
+	PickedCandidate      int    // -pick で選んだ候補の番号 (1から、選んでいない場合は0)
 }
 
-// generateContentをサポートする利用可能なモデルを標準エラー出力にリストする
-func listAvailableModels(ctx context.Context, client *genai.Client) {
+// 経過時間の上限 (-max-duration) に達してストリームを打ち切った場合の終了理由
+// APIの終了理由ではなく、このツールが記録するもの
+const finishReasonMaxDuration genai.FinishReason = "MAX_DURATION"
+
+// 経過時間の上限に達したことを表すキャンセルの原因
+var errMaxDurationExceeded = errors.New("max duration exceeded")
+
+// スループットを計算する最小のAPI呼び出し時間 (これより短い場合は計算しない)
+const minThroughputCallTime = time.Millisecond
+
+// 出力トークン数をAPI呼び出し時間で割った出力のスループット (トークン/秒) を返す
+// 呼び出し時間がほぼ0の場合や出力トークンがない場合はfalseを返す
+func (m LLMMetadata) outputTokensPerSecond() (float64, bool) {
+	if m.APICallTime < minThroughputCallTime || m.CandidatesTokenCount <= 0 {
+		return 0, false
+	}
+	return float64(m.CandidatesTokenCount) / m.APICallTime.Seconds(), true
+}
+
+// Google検索によるグラウンディングの参照元
+type GroundingSource struct {
+	Title string
+	URI   string
+}
+
+// ストリーミングでコンテンツを生成するクライアントのインターフェース
+// *genai.Models がこれを満たす。テスト時には偽の実装に差し替えられる
+type contentStreamer interface {
+	GenerateContentStream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error]
+}
+
+// generateContentをサポートする利用可能なモデルを取得する
+// ページの取得中にエラーが発生した場合は、それまでに取得したモデルとエラーを返す
+func fetchAvailableModels(ctx context.Context, client *genai.Client) ([]*genai.Model, error) {
 	pageSize := int32(20)
 	var listModelsConfig = genai.ListModelsConfig{
 		PageSize: pageSize,
 	}
 	iter, err := client.Models.List(ctx, &listModelsConfig)
 	if err != nil {
-		log.Printf("Error listing models: %v", err)
-		return
+		return nil, errorf("err.listModels", err)
 	}
 
+	var available []*genai.Model
 	for {
 		models := iter.Items
 		for _, m := range models {
-			supportsGenerateContent := false
 			if slices.Contains(m.SupportedActions, "generateContent") {
-				supportsGenerateContent = true
-			}
-
-			if supportsGenerateContent {
-				fmt.Fprintln(os.Stderr, "- ", m.Name, "\n    ", m.Description)
+				available = append(available, m)
 			}
 		}
 		iter, err = iter.Next(ctx)
@@ -64,45 +119,139 @@ func listAvailableModels(ctx context.Context, client *genai.Client) {
 			break
 		}
 		if err != nil {
-			log.Printf("Error going to next page: %v", err)
-			break
+			return available, errorf("err.nextPage", err)
+		}
+	}
+	return available, nil
+}
+
+// generateContentをサポートする利用可能なモデルを標準エラー出力にリストする
+func listAvailableModels(ctx context.Context, client *genai.Client) {
+	models, err := cachedAvailableModels(ctx, client)
+	printModels(os.Stderr, models)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// 一覧に表示するモデルの最大数 (-models-limit、0の場合は無制限)
+// モデル名の順に並べるため、取得はすべてのページについて行い、表示する数のみを制限する
+var modelsLimit int
+
+// モデルの一覧をモデル名の順にwに表示する
+// 表示数の上限を超える場合は、表示しなかったモデルの数を表示する
+func printModels(w io.Writer, models []*genai.Model) {
+	sorted := slices.SortedFunc(slices.Values(models), func(a, b *genai.Model) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	if modelsLimit > 0 && len(sorted) > modelsLimit {
+		defer fmt.Fprintln(w, msgf("status.moreModels", len(sorted)-modelsLimit))
+		sorted = sorted[:modelsLimit]
+	}
+	for _, m := range sorted {
+		fmt.Fprintln(w, "- ", m.Name, "\n    ", m.Description)
+	}
+}
+
+// モデル名または表示名に部分文字列 (大文字小文字を区別しない) を含むモデルだけを返す
+// フィルタが空の場合はすべてのモデルを返す
+func filterModels(models []*genai.Model, filter string) []*genai.Model {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return models
+	}
+	var filtered []*genai.Model
+	for _, m := range models {
+		if strings.Contains(strings.ToLower(m.Name), filter) || strings.Contains(strings.ToLower(m.DisplayName), filter) {
+			filtered = append(filtered, m)
 		}
 	}
+	return filtered
+}
+
+// モデルのリソース名 (models/xxx や publishers/google/models/xxx) から短い名前を取り出す
+func shortModelName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// 指定されたモデルがgenerateContentをサポートする利用可能なモデルに含まれるかを確認する
+// 含まれない場合は利用可能なモデルの一覧を表示してエラーを返す
+func checkModelAvailable(ctx context.Context, client *genai.Client, modelName string) error {
+	models, err := cachedAvailableModels(ctx, client)
+	if err != nil {
+		return errorf("err.listModels", err)
+	}
+	requested := shortModelName(modelName)
+	for _, m := range models {
+		if shortModelName(m.Name) == requested {
+			return nil
+		}
+	}
+	// -eventsの場合は標準エラー出力をJSONの行のみにするため、モデルの一覧を表示しない
+	if events == nil {
+		fmt.Fprintln(os.Stderr, msg("status.availableModels"))
+		printModels(os.Stderr, models)
+	}
+	return &modelNotFoundError{Model: modelName}
 }
 
-// 設定に基づいてクライアントをGemini APIまたはVertex AIクライアントとして初期化する
-func initClient(ctx context.Context, settings *Settings) (*genai.Client, string, error) {
-	switch settings.APIMethod {
+// プロファイルに基づいてクライアントをGemini APIまたはVertex AIクライアントとして初期化する
+func initClient(ctx context.Context, profile *Profile) (*genai.Client, string, error) {
+	return initClientWithKey(ctx, profile, 0)
+}
+
+// initClientと同様にクライアントを初期化する
+// APIキーを使う場合は、keyIndex番目 (0が主キー) のAPIキーを使う
+func initClientWithKey(ctx context.Context, profile *Profile, keyIndex int) (*genai.Client, string, error) {
+	switch profile.APIMethod {
 	case "apiKey":
 		// APIキーを使う場合
-		apiKey := os.Getenv(settings.APIKeyConfig.APIKeyEnvVarName)
-		if apiKey == "" {
-			return nil, "", fmt.Errorf("環境変数 '%s' にAPIキーが設定されていません", settings.APIKeyConfig.APIKeyEnvVarName)
+		keys := profile.APIKeyConfig.candidates()
+		if keyIndex < 0 || keyIndex >= len(keys) {
+			return nil, "", errorf("err.apiKeyIndex", keyIndex)
+		}
+		apiKey, err := keys[keyIndex].resolveAPIKey()
+		if err != nil {
+			return nil, "", err
 		}
 
 		client, err := genai.NewClient(ctx, &genai.ClientConfig{
 			APIKey:  apiKey,
 			Backend: genai.BackendGeminiAPI,
+			HTTPOptions: genai.HTTPOptions{
+				BaseURL:    profile.BaseURL,
+				APIVersion: profile.APIVersion,
+			},
 		})
 		if err != nil {
-			return nil, "", fmt.Errorf("Gemini APIクライアントの初期化に失敗しました: %w", err)
+			return nil, "", errorf("err.initGeminiClient", err)
 		}
 		return client, "Gemini API", nil
 
 	case "vertexAI":
 		// Vertex AIを使う場合
+		if profile.BaseURL != "" {
+			return nil, "", errorf("err.baseURLBackend")
+		}
 		client, err := genai.NewClient(ctx, &genai.ClientConfig{
-			Project:  settings.VertexAIConfig.Project,
-			Location: settings.VertexAIConfig.Location,
+			Project:  profile.VertexAIConfig.Project,
+			Location: profile.VertexAIConfig.Location,
 			Backend:  genai.BackendVertexAI,
+			HTTPOptions: genai.HTTPOptions{
+				APIVersion: profile.APIVersion,
+			},
 		})
 		if err != nil {
-			return nil, "", fmt.Errorf("Vertex AIクライアントの初期化に失敗しました: %w", err)
+			return nil, "", errorf("err.initVertexClient", err)
 		}
 		return client, "Vertex AI", nil
 
 	default:
-		return nil, "", fmt.Errorf("無効なAPIメソッド: %s", settings.APIMethod)
+		return nil, "", errorf("err.invalidAPIMethod", profile.APIMethod)
 	}
 }
 
@@ -118,7 +267,7 @@ func parseThinkingLevel(level string) (genai.ThinkingLevel, error) {
 	case "high":
 		return genai.ThinkingLevelHigh, nil
 	default:
-		return "", fmt.Errorf("無効な -think-level が指定されました: %s (指定可能: minimal|low|medium|high)", level)
+		return "", errorf("err.invalidThinkLevel", level)
 	}
 }
 
@@ -128,8 +277,68 @@ func isGemini3ProModel(modelName string) bool {
 	return strings.HasPrefix(name, "gemini-3-pro")
 }
 
+// モデルや思考設定などリクエストに関するユーザー指定のオプション
+type requestOptions struct {
+	ModelName                 string
+	Thinking                  bool
+	HideThoughts              bool // 思考は有効のまま、思考プロセスのテキストを受け取らない
+	ThinkingSet               bool // 思考関連のフラグが明示的に指定されたか (falseの場合はタスクのデフォルトを使う)
+	ThinkingLevel             string
+	ThinkingBudget            *int32 // nilの場合はデフォルトの思考予算を使う
+	DefaultThinking           bool   // 思考関連のフラグが未指定の場合に思考を有効にするか (設定ファイルから)
+	DefaultThinkingLevel      string // -think-level 未指定時の思考レベル (設定ファイルから)
+	DefaultThinkingBudget     int32  // -think-budget 未指定時の思考予算 (設定ファイルから、0の場合はdefaultThinkingBudget)
+	Grounding                 bool
+	Tone                      string
+	Bullets                   *bool         // nilの場合はタスクのデフォルトの出力形式を使う
+	TargetLanguageInstruction string        // 翻訳先の言語に固有の追加指示 (設定ファイルから)
+	MaxTotalTokens            int32         // 合計トークン数の上限 (0の場合は無制限)
+	MaxTokens                 int32         // 最大出力トークン数 (0の場合は入力の長さから計算する)
+	Romaji                    bool          // 日本語の出力にローマ字表記を添える
+	CandidateCount            int32         // 生成する候補の数 (0または1の場合は1つ)
+	ResponseSchema            any           // 構造化出力のJSONスキーマ (nilの場合はテキストで出力)
+	MaxDuration               time.Duration // 1回のリクエストの経過時間の上限 (0の場合は無制限)
+	Glossary                  string        // システム指示に埋め込む用語集 (用語集を使うタスクのみ)
+	Seed                      *int32        // 生成の乱数シード (nilの場合は指定しない)
+	StopSequences             []string      // 生成を打ち切る文字列
+	Pick                      string        // 複数の候補から1つを選んで出力する方法 (空の場合はすべて出力)
+	SystemPrefix              string        // すべてのタスクのシステム指示の先頭に加える指示
+}
+
+// 思考を有効にした場合のデフォルトの思考予算 (Gemini 3以外のモデル向け)
+const defaultThinkingBudget int32 = 1024
+
+// -candidatesで指定できる候補の数の上限
+const maxCandidates = 8
+
+// -stopで指定できる文字列の数の上限 (APIの制限)
+const maxStopSequences = 5
+
 // TaskDefinitionに基づいてLlmRequestConfigとgenai.GenerateContentConfigを作成する
-func createLLMConfigs(task TaskDefinition, modelName string, inputText string, enableThinking bool, requestedThinkingLevel string) (LlmRequestConfig, *genai.GenerateContentConfig, error) {
+func createLLMConfigs(task TaskDefinition, inputText string, image *imageInput, reqOpts requestOptions) (LlmRequestConfig, *genai.GenerateContentConfig, error) {
+	modelName := reqOpts.ModelName
+	enableThinking := reqOpts.Thinking
+	requestedThinkingLevel := reqOpts.ThinkingLevel
+	enableGrounding := reqOpts.Grounding
+
+	// 思考レベルが指定されていない場合は設定ファイルの値を使う
+	if strings.TrimSpace(requestedThinkingLevel) == "" {
+		requestedThinkingLevel = reqOpts.DefaultThinkingLevel
+	}
+
+	// 思考関連のフラグが指定されていない場合は設定ファイル、次にタスクのデフォルトを使う
+	if !reqOpts.ThinkingSet {
+		switch {
+		case reqOpts.DefaultThinking:
+			enableThinking = true
+		case task.DefaultThinking:
+			enableThinking = true
+			if strings.TrimSpace(requestedThinkingLevel) == "" {
+				requestedThinkingLevel = task.DefaultThinkingLevel
+			}
+		}
+	}
+
 	var includeThoughts = false
 	var thinkingBudgetValue int32 = 0
 	var thinkingBudget *int32
@@ -138,6 +347,15 @@ func createLLMConfigs(task TaskDefinition, modelName string, inputText string, e
 	isGemini3 := isGemini3Model(modelName)
 	isGemini3Pro := isGemini3ProModel(modelName)
 
+	if reqOpts.ThinkingBudget != nil {
+		if isGemini3 {
+			return LlmRequestConfig{}, nil, errorf("err.thinkBudgetGemini3")
+		}
+		if *reqOpts.ThinkingBudget < 0 {
+			return LlmRequestConfig{}, nil, errorf("err.negativeThinkBudget", *reqOpts.ThinkingBudget)
+		}
+	}
+
 	if isGemini3 {
 		if enableThinking {
 			if strings.TrimSpace(requestedThinkingLevel) != "" {
@@ -147,37 +365,106 @@ func createLLMConfigs(task TaskDefinition, modelName string, inputText string, e
 				}
 				thinkingLevel = parsedLevel
 			} else {
-				return LlmRequestConfig{}, nil, fmt.Errorf("Gemini3シリーズでは -think-level が必須です")
+				return LlmRequestConfig{}, nil, errorf("err.thinkLevelRequired")
 			}
 		} else {
 			thinkingLevel = genai.ThinkingLevelMinimal
 		}
 
 		if isGemini3Pro && thinkingLevel != genai.ThinkingLevelLow && thinkingLevel != genai.ThinkingLevelHigh {
-			return LlmRequestConfig{}, nil, fmt.Errorf("モデル '%s' では -think-level は low または high のみ指定可能です", modelName)
+			return LlmRequestConfig{}, nil, errorf("err.thinkLevelLowHigh", modelName)
 		}
 	} else if enableThinking {
-		thinkingBudgetValue = 1024
+		thinkingBudgetValue = defaultThinkingBudget
+		if reqOpts.ThinkingBudget != nil {
+			thinkingBudgetValue = *reqOpts.ThinkingBudget
+		} else if reqOpts.DefaultThinkingBudget > 0 {
+			thinkingBudgetValue = reqOpts.DefaultThinkingBudget
+		}
 		thinkingBudget = &thinkingBudgetValue
 	} else {
 		thinkingBudget = &thinkingBudgetValue
 	}
 
-	includeThoughts = enableThinking
+	// -no-thoughts の場合は思考を有効にしたまま、思考プロセスのテキストを受け取らない
+	includeThoughts = enableThinking && !reqOpts.HideThoughts
+
+	// 最大出力トークン数が指定されていない場合は入力の長さとタスクの係数から計算し、タスクの上限で制限する
+	// 上限は表示する出力の分に適用し、思考予算はその後に加える (上限で出力の分がなくならないようにする)
+	maxTokens := reqOpts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = int32(len(inputText))*task.MaxTokensMultiplier + task.MaxTokensBase
+		if task.MaxTokensCap > 0 {
+			maxTokens = min(maxTokens, task.MaxTokensCap)
+		}
+		if thinkingBudget != nil {
+			maxTokens += *thinkingBudget
+		}
+	}
+
+	// 画像が添付されている場合はタスクの画像用指示をシステム指示に追加する
+	systemInstruction, err := task.systemInstructionWithTone(reqOpts.Tone)
+	if err != nil {
+		return LlmRequestConfig{}, nil, err
+	}
+	systemInstruction, err = task.applyOutputFormat(systemInstruction, reqOpts.Bullets)
+	if err != nil {
+		return LlmRequestConfig{}, nil, err
+	}
+	systemInstruction, err = task.applyGlossary(systemInstruction, reqOpts.Glossary)
+	if err != nil {
+		return LlmRequestConfig{}, nil, err
+	}
+	if reqOpts.TargetLanguageInstruction != "" {
+		systemInstruction += "\n" + reqOpts.TargetLanguageInstruction
+	}
+	if reqOpts.Romaji {
+		systemInstruction += "\n" + romajiInstruction
+	}
+	if image != nil {
+		if task.ImageInstruction == "" {
+			return LlmRequestConfig{}, nil, errorf("err.imageUnsupported", task.Name)
+		}
+		systemInstruction += "\n" + task.ImageInstruction
+	}
+	// 共通の指示はタスクの指示と改行で区切って先頭に加える ({{date}} などの置き換えも行う)
+	systemPrefix := strings.TrimSpace(reqOpts.SystemPrefix)
+	if systemPrefix != "" {
+		systemInstruction = systemPrefix + "\n" + systemInstruction
+	}
+	systemInstruction = task.expandTemplateVariables(systemInstruction, time.Now())
+
+	// グラウンディングはタスクが対応している場合のみ有効にできる
+	if enableGrounding && !task.AllowGrounding {
+		return LlmRequestConfig{}, nil, errorf("err.groundUnsupported", task.Name)
+	}
 
-	maxTokens := int32(len(inputText))*task.MaxTokensMultiplier + task.MaxTokensBase
-	if thinkingBudget != nil {
-		maxTokens += *thinkingBudget
+	// 構造化出力はツールと併用できないため、グラウンディングとは同時に指定できない
+	if reqOpts.ResponseSchema != nil && enableGrounding {
+		return LlmRequestConfig{}, nil, errorf("err.schemaWithGround")
 	}
 
+	// 入力はプレフィックス/サフィックスで区切るだけでXMLタグなどで囲まないため、エスケープは行わない
+	// (エスケープするとコード中の <, >, & などが出力で正しく復元されないことがある)
 	llmRequestConfig := LlmRequestConfig{
-		SystemInstruction: task.SystemInstruction,
+		SystemInstruction: systemInstruction,
+		SystemPrefix:      systemPrefix,
 		Model:             modelName,
 		MaxTokens:         maxTokens,
-		InputText:         task.InputPrefix + html.EscapeString(inputText) + task.InputSuffix,
+		InputText:         task.InputPrefix + inputText + task.InputSuffix,
+		Image:             image,
 		IncludeThoughts:   includeThoughts,
 		ThinkingBudget:    thinkingBudget,
 		ThinkingLevel:     thinkingLevel,
+		Grounding:         enableGrounding,
+		MaxTotalTokens:    reqOpts.MaxTotalTokens,
+		ResponseSchema:    reqOpts.ResponseSchema,
+		CandidateCount:    reqOpts.CandidateCount,
+		MaxDuration:       reqOpts.MaxDuration,
+		Seed:              reqOpts.Seed,
+		StopSequences:     reqOpts.StopSequences,
+		Pick:              reqOpts.Pick,
+		TaskName:          task.Name,
 	}
 
 	var config *genai.GenerateContentConfig
@@ -208,6 +495,24 @@ func createLLMConfigs(task TaskDefinition, modelName string, inputText string, e
 			},
 		}
 	}
+	if llmRequestConfig.Seed != nil {
+		config.Seed = llmRequestConfig.Seed
+	}
+	if len(llmRequestConfig.StopSequences) > 0 {
+		config.StopSequences = llmRequestConfig.StopSequences
+	}
+	if llmRequestConfig.CandidateCount > 1 {
+		config.CandidateCount = llmRequestConfig.CandidateCount
+	}
+	if llmRequestConfig.ResponseSchema != nil {
+		config.ResponseMIMEType = jsonMIMEType
+		config.ResponseJsonSchema = llmRequestConfig.ResponseSchema
+	}
+	if llmRequestConfig.Grounding {
+		config.Tools = []*genai.Tool{
+			{GoogleSearch: &genai.GoogleSearch{}},
+		}
+	}
 	return llmRequestConfig, config, nil
 }
 
@@ -217,48 +522,136 @@ func isGemini3Model(modelName string) bool {
 	return strings.HasPrefix(name, "gemini-3")
 }
 
-// Gemini APIにリクエストを送信し、ストリームされたコンテンツをoutputChanに送信する
+// 履歴、入力テキスト、添付画像からリクエストのContentを組み立てる
+func buildContents(llmReqConfig LlmRequestConfig) []*genai.Content {
+	contents := slices.Clone(llmReqConfig.History)
+	if llmReqConfig.Image == nil {
+		return append(contents, genai.Text(llmReqConfig.InputText)...)
+	}
+	parts := []*genai.Part{
+		genai.NewPartFromBytes(llmReqConfig.Image.Data, llmReqConfig.Image.MIMEType),
+		genai.NewPartFromText(llmReqConfig.InputText),
+	}
+	return append(contents, genai.NewContentFromParts(parts, genai.RoleUser))
+}
+
+// -debugフラグが指定された場合にレスポンス構造を出力する先 (nilの場合は出力しない)
+var debugOutput io.Writer
+
+// レスポンスをJSONに整形し、翻訳結果と区別できるよう区切り線で囲んで出力する
+func printDebugResponse(w io.Writer, result *genai.GenerateContentResponse) {
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		fmt.Fprintf(w, "\n==== DEBUG: %s ====\n", msgf("err.serializeResponse", err))
+		return
+	}
+	fmt.Fprintf(w, "\n==== DEBUG: API Response Structure ====\n")
+	fmt.Fprintf(w, "%s\n", string(resultJSON))
+	fmt.Fprintf(w, "=====================================\n\n")
+}
+
+// Gemini APIにリクエストを送信し、ストリームされたコンテンツを書き込む
+// 回答はout、思考プロセスはthoughtOutに書き込む (思考プロセスの色付けは呼び出し側で行う)
 // メタデータを収集し、エラーが発生した場合はそれを返す
-func streamContent(ctx context.Context, client *genai.Client, llmReqConfig LlmRequestConfig, genaiConfig *genai.GenerateContentConfig, outputChan chan<- string) (LLMMetadata, error) {
+func streamContent(ctx context.Context, streamer contentStreamer, llmReqConfig LlmRequestConfig, genaiConfig *genai.GenerateContentConfig, out io.Writer, thoughtOut io.Writer) (metadata LLMMetadata, err error) {
+	// トークン数の上限を超えた場合にストリームを中断できるようにする
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// レートリミッタで待機した時間はAPI呼び出し時間に含めない
+	if err := waitRequestLimiter(ctx); err != nil {
+		return LLMMetadata{}, err
+	}
+
+	// 監査ログが有効な場合は、送信前に記録し、記録できなければAPIを呼び出さずに終了する
+	// 結果は成功・失敗に関わらず終了時に記録する
+	auditRequestID, err := auditLog.begin(llmReqConfig)
+	if err != nil {
+		return LLMMetadata{}, err
+	}
+	defer func() {
+		if auditErr := auditLog.record(auditRequestID, llmReqConfig, metadata, err); auditErr != nil {
+			err = errors.Join(err, auditErr)
+		}
+	}()
+
+	// 経過時間の上限が指定されている場合は、トークンが届いていても上限に達した時点で打ち切る
+	if llmReqConfig.MaxDuration > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, llmReqConfig.MaxDuration, errMaxDurationExceeded)
+		defer cancelTimeout()
+	}
+
 	start := time.Now()
-	stream := client.Models.GenerateContentStream(ctx, llmReqConfig.Model, genai.Text(llmReqConfig.InputText), genaiConfig)
+	stream := streamer.GenerateContentStream(ctx, llmReqConfig.Model, buildContents(llmReqConfig), genaiConfig)
 
-	var metadata LLMMetadata
+	metadata = LLMMetadata{Model: llmReqConfig.Model, Grounding: llmReqConfig.Grounding, Seed: llmReqConfig.Seed}
+	// 文字数・単語数の集計用に思考プロセス以外の出力を保持する
+	var outputText strings.Builder
+	// ブロックや途中終了の判定用に、プロンプトのブロック理由と最後の候補の終了理由を保持する
+	var blockReason genai.BlockedReason
+	var finishReason genai.FinishReason
+	// 複数の候補を生成する場合は、候補ごとのテキストが混ざらないよう溜めておき最後にまとめて出力する
+	multiCandidate := llmReqConfig.CandidateCount > 1
+	candidateTexts := make([]strings.Builder, max(llmReqConfig.CandidateCount, 1))
 
 	// ストリームから結果を読み込み、出力チャネルに送信
 	for result, err := range stream {
 		if err != nil {
-			// エラーメッセージが404を含む場合、モデル一覧を表示する
-			if strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "not found") {
-				fmt.Fprintln(os.Stderr, err.Error())
-				listAvailableModels(ctx, client)
-				return metadata, fmt.Errorf("指定されたモデル '%s' が見つからないか、generateContentをサポートしていません: %w", llmReqConfig.Model, err)
+			// 経過時間の上限に達した場合は、受信済みの出力で終了する
+			if errors.Is(context.Cause(ctx), errMaxDurationExceeded) {
+				finishReason = finishReasonMaxDuration
+				break
+			}
+			// APIエラーのステータスが404の場合、モデルが見つからない旨のエラーを返す
+			// (エラーとモデル一覧の表示は呼び出し側で行う)
+			if isNotFoundError(err) {
+				return metadata, &modelNotFoundError{Model: llmReqConfig.Model, Err: err}
 			}
 			// その他のエラーの場合はそのまま返す
-			return metadata, fmt.Errorf("API呼び出し中にエラーが発生しました: %w", err)
+			// 回答の出力が始まった後の場合は、途中までの出力が不完全であることを示すエラーにする
+			err = errorf("err.apiCall", err)
+			if outputText.Len() > 0 {
+				if multiCandidate {
+					writeCandidates(out, candidateTexts)
+				}
+				return metadata, &incompleteOutputError{Err: err}
+			}
+			return metadata, err
 		}
 
-		// // デバッグ: レスポンス構造を出力
-		// if result != nil {
-		// 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
-		// 	fmt.Fprintf(os.Stderr, "\n==== DEBUG: API Response Structure ====\n")
-		// 	fmt.Fprintf(os.Stderr, "%s\n", string(resultJSON))
-		// 	fmt.Fprintf(os.Stderr, "=====================================\n\n")
-		// }
+		// デバッグ: レスポンス構造を出力
+		if debugOutput != nil && result != nil {
+			printDebugResponse(debugOutput, result)
+		}
 
 		// 結果を出力
 		if result != nil && result.Candidates != nil {
 			for _, cand := range result.Candidates {
+				if cand != nil && cand.FinishReason != "" {
+					finishReason = cand.FinishReason
+				}
+				if cand != nil && cand.GroundingMetadata != nil {
+					metadata.GroundingSources = appendGroundingSources(metadata.GroundingSources, cand.GroundingMetadata)
+				}
 				if cand != nil && cand.Content != nil && cand.Content.Parts != nil {
 					for _, part := range cand.Content.Parts {
 						if part != nil && part.Text != "" {
-							var text string
+							if metadata.TimeToFirstToken == 0 {
+								metadata.TimeToFirstToken = time.Since(start)
+							}
 							if part.Thought == true {
-								text = color.BlueString(html.UnescapeString(part.Text))
+								// 複数の候補の思考プロセスは混ざって読めないため、最初の候補のものだけを表示する
+								if cand.Index == 0 {
+									io.WriteString(thoughtOut, part.Text)
+								}
+							} else if multiCandidate && int(cand.Index) < len(candidateTexts) {
+								candidateTexts[cand.Index].WriteString(part.Text)
+								outputText.WriteString(part.Text)
 							} else {
-								text = html.UnescapeString(part.Text)
+								io.WriteString(out, part.Text)
+								outputText.WriteString(part.Text)
 							}
-							outputChan <- text
 						}
 					}
 				}
@@ -267,6 +660,9 @@ func streamContent(ctx context.Context, client *genai.Client, llmReqConfig LlmRe
 
 		// メタデータを更新
 		if result != nil {
+			if result.PromptFeedback != nil && result.PromptFeedback.BlockReason != "" {
+				blockReason = result.PromptFeedback.BlockReason
+			}
 			metadata.ModelVersion = result.ModelVersion
 			if result.UsageMetadata != nil {
 				metadata.TotalTokenCount = result.UsageMetadata.TotalTokenCount
@@ -275,22 +671,189 @@ func streamContent(ctx context.Context, client *genai.Client, llmReqConfig LlmRe
 				metadata.ThoughtsTokenCount = result.UsageMetadata.ThoughtsTokenCount
 			}
 		}
+
+		// 合計トークン数が上限を超えた場合は中断する
+		// (使用量は通常最後のチャンクで届くため、上限の確認はベストエフォート)
+		if llmReqConfig.MaxTotalTokens > 0 && metadata.TotalTokenCount > llmReqConfig.MaxTotalTokens {
+			cancel()
+			metadata.APICallTime = time.Since(start)
+			return metadata, errorf("err.tokenBudgetExceeded", metadata.TotalTokenCount, llmReqConfig.MaxTotalTokens)
+		}
 	}
 	metadata.APICallTime = time.Since(start)
+	if finishReason == "" && errors.Is(context.Cause(ctx), errMaxDurationExceeded) {
+		finishReason = finishReasonMaxDuration
+	}
+	if multiCandidate && llmReqConfig.Pick != "" {
+		picked := pickCandidate(candidateTexts, llmReqConfig.Pick)
+		io.WriteString(out, candidateTexts[picked].String())
+		metadata.PickedCandidate = picked + 1
+	} else if multiCandidate {
+		writeCandidates(out, candidateTexts)
+	}
+	metadata.OutputCharCount = utf8.RuneCountInString(outputText.String())
+	metadata.OutputWordCount = len(strings.Fields(outputText.String()))
+	metadata.FinishReason = string(finishReason)
 
+	if err := checkCompletion(blockReason, finishReason, outputText.Len() > 0); err != nil {
+		return metadata, err
+	}
 	return metadata, nil
 }
 
-// メタデータを出力
-func printMetadata(metadata LLMMetadata, apiMethod string, taskName string) {
-	fmt.Fprintln(os.Stderr, "==== Metadata ====")
-	fmt.Fprintln(os.Stderr, "✓ Task:                  ", taskName)
-	fmt.Fprintln(os.Stderr, "✓ API method:            ", apiMethod)
-	fmt.Fprintln(os.Stderr, "✓ API call time:         ", metadata.APICallTime)
-	fmt.Fprintln(os.Stderr, "✓ Model version:         ", metadata.ModelVersion)
-	fmt.Fprintln(os.Stderr, "✓ Prompt token count:    ", metadata.PromptTokenCount)
-	fmt.Fprintln(os.Stderr, "✓ Candidate token count: ", metadata.CandidatesTokenCount)
-	fmt.Fprintln(os.Stderr, "✓ Thoughts token count:  ", metadata.ThoughtsTokenCount)
-	fmt.Fprintln(os.Stderr, "✓ Total token count:     ", metadata.TotalTokenCount)
-	fmt.Fprintln(os.Stderr, "==================")
+// プロンプトのブロック理由と終了理由から、応答が正常に完了したかを確認する
+// ブロックされた場合や空の応答の場合はエラーを返す
+// 最大出力トークン数で打ち切られた場合は途中までの出力が不完全であることを示すエラーを返し、経過時間の上限で打ち切られた場合は警告を表示する
+func checkCompletion(blockReason genai.BlockedReason, finishReason genai.FinishReason, hasOutput bool) error {
+	if blockReason != "" {
+		return &contentBlockedError{Reason: string(blockReason)}
+	}
+
+	switch finishReason {
+	case "", genai.FinishReasonStop:
+		// 正常終了
+	case genai.FinishReasonMaxTokens:
+		if hasOutput {
+			return &incompleteOutputError{Err: errors.New(msg("err.maxTokensReached"))}
+		}
+	case finishReasonMaxDuration:
+		printNotice(msg("warn.maxDuration"))
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation, genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent, genai.FinishReasonSPII,
+		genai.FinishReasonImageSafety, genai.FinishReasonImageProhibitedContent:
+		return &contentBlockedError{Reason: string(finishReason)}
+	default:
+		printNotice(msgf("warn.unexpectedFinish", finishReason))
+	}
+
+	if !hasOutput {
+		return errorf("err.emptyResponse", finishReason)
+	}
+	return nil
+}
+
+// -verboseフラグ指定時に、解決したリクエスト設定を標準エラー出力に表示する
+func printVerbose(llmReqConfig LlmRequestConfig, taskName string, inputFiles []string) {
+	thinking := (llmReqConfig.ThinkingBudget != nil && *llmReqConfig.ThinkingBudget > 0) ||
+		(llmReqConfig.ThinkingLevel != "" && llmReqConfig.ThinkingLevel != genai.ThinkingLevelMinimal)
+	fmt.Fprintln(os.Stderr, "==== Request ====")
+	fmt.Fprintln(os.Stderr, "✓ Model:           ", llmReqConfig.Model)
+	fmt.Fprintln(os.Stderr, "✓ Task:            ", taskName)
+	fmt.Fprintln(os.Stderr, "✓ Thinking:        ", thinking)
+	if llmReqConfig.ThinkingBudget != nil {
+		fmt.Fprintln(os.Stderr, "✓ Thinking budget: ", *llmReqConfig.ThinkingBudget)
+	}
+	if llmReqConfig.ThinkingLevel != "" {
+		fmt.Fprintln(os.Stderr, "✓ Thinking level:  ", llmReqConfig.ThinkingLevel)
+	}
+	fmt.Fprintln(os.Stderr, "✓ Max tokens:      ", llmReqConfig.MaxTokens)
+	if llmReqConfig.SystemPrefix != "" {
+		fmt.Fprintln(os.Stderr, "✓ System prefix:   ", llmReqConfig.SystemPrefix)
+	}
+	if len(inputFiles) > 0 {
+		fmt.Fprintln(os.Stderr, "✓ Input files:")
+		for _, path := range inputFiles {
+			fmt.Fprintf(os.Stderr, "    - %s\n", path)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "=================")
+}
+
+// -dry-run用に、APIへ送信する予定のリクエスト内容を出力
+func printDryRun(llmReqConfig LlmRequestConfig) {
+	fmt.Println("==== Dry run ====")
+	fmt.Println("✓ Model:           ", llmReqConfig.Model)
+	fmt.Println("✓ Max tokens:      ", llmReqConfig.MaxTokens)
+	fmt.Println("✓ Include thoughts:", llmReqConfig.IncludeThoughts)
+	if llmReqConfig.ThinkingBudget != nil {
+		fmt.Println("✓ Thinking budget: ", *llmReqConfig.ThinkingBudget)
+	}
+	if llmReqConfig.ThinkingLevel != "" {
+		fmt.Println("✓ Thinking level:  ", llmReqConfig.ThinkingLevel)
+	}
+	if llmReqConfig.Grounding {
+		fmt.Println("✓ Grounding:        Google Search")
+	}
+	if llmReqConfig.CandidateCount > 1 {
+		fmt.Println("✓ Candidates:      ", llmReqConfig.CandidateCount)
+	}
+	if llmReqConfig.ResponseSchema != nil {
+		fmt.Println("✓ Response MIME:   ", jsonMIMEType)
+	}
+	if llmReqConfig.SystemPrefix != "" {
+		fmt.Println("✓ System prefix:   ", llmReqConfig.SystemPrefix)
+	}
+	if llmReqConfig.Image != nil {
+		fmt.Printf("✓ Image:            %s (%s, %d bytes)\n", llmReqConfig.Image.Path, llmReqConfig.Image.MIMEType, len(llmReqConfig.Image.Data))
+	}
+	fmt.Println("==== System instruction ====")
+	fmt.Println(llmReqConfig.SystemInstruction)
+	fmt.Println("==== Input text ====")
+	fmt.Println(llmReqConfig.InputText)
+	fmt.Println("=================")
+}
+
+// 候補ごとのテキストを番号付きの見出しを付けて出力する
+func writeCandidates(out io.Writer, candidateTexts []strings.Builder) {
+	for i := range candidateTexts {
+		if i > 0 {
+			io.WriteString(out, "\n")
+		}
+		fmt.Fprintf(out, "==== Candidate %d ====\n", i+1)
+		text := candidateTexts[i].String()
+		io.WriteString(out, text)
+		if !strings.HasSuffix(text, "\n") {
+			io.WriteString(out, "\n")
+		}
+	}
+}
+
+// -pick で指定できる候補の選び方
+const (
+	pickShortest = "shortest" // 最も短い候補
+	pickLongest  = "longest"  // 最も長い候補
+	pickFirst    = "first"    // 最初の候補
+)
+
+// -pick の値を検証する
+func validatePick(pick string) error {
+	switch pick {
+	case "", pickShortest, pickLongest, pickFirst:
+		return nil
+	default:
+		return errorf("err.invalidPick", pick, pickShortest, pickLongest, pickFirst)
+	}
+}
+
+// 候補の中からpickの方法で1つを選び、そのインデックスを返す
+// 長さは前後の空白を除いた文字数で比べ、空の候補は選ばない (同じ長さの場合は先の候補を選ぶ)
+func pickCandidate(candidateTexts []strings.Builder, pick string) int {
+	picked, pickedLength := 0, -1
+	for i := range candidateTexts {
+		length := utf8.RuneCountInString(strings.TrimSpace(candidateTexts[i].String()))
+		if length == 0 {
+			continue
+		}
+		if pick == pickFirst {
+			return i
+		}
+		if pickedLength < 0 || (pick == pickShortest && length < pickedLength) || (pick == pickLongest && length > pickedLength) {
+			picked, pickedLength = i, length
+		}
+	}
+	return picked
+}
+
+// グラウンディングメタデータから参照元を重複なく追加する
+func appendGroundingSources(sources []GroundingSource, groundingMetadata *genai.GroundingMetadata) []GroundingSource {
+	for _, chunk := range groundingMetadata.GroundingChunks {
+		if chunk == nil || chunk.Web == nil || chunk.Web.URI == "" {
+			continue
+		}
+		if slices.ContainsFunc(sources, func(s GroundingSource) bool { return s.URI == chunk.Web.URI }) {
+			continue
+		}
+		sources = append(sources, GroundingSource{Title: chunk.Web.Title, URI: chunk.Web.URI})
+	}
+	return sources
 }
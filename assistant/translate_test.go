@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// 用意したレスポンスを順に返し、最後にerrを返すcontentStreamerの偽物
+type fakeStreamer struct {
+	responses []*genai.GenerateContentResponse
+	err       error
+	contents  []*genai.Content // 最後に受け取った入力
+	calls     int              // GenerateContentStreamが呼び出された回数
+}
+
+func (f *fakeStreamer) GenerateContentStream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	f.contents = contents
+	f.calls++
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		for _, resp := range f.responses {
+			if !yield(resp, nil) {
+				return
+			}
+		}
+		if f.err != nil {
+			yield(nil, f.err)
+		}
+	}
+}
+
+// 1つの候補にpartsを含むレスポンスを作成する
+func candidateResponse(finishReason genai.FinishReason, parts ...*genai.Part) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{
+			Content:      &genai.Content{Role: genai.RoleModel, Parts: parts},
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+// 思考プロセスのパートを作成する
+func thoughtPart(text string) *genai.Part {
+	return &genai.Part{Text: text, Thought: true}
+}
+
+// 偽物のストリームでstreamContentを実行し、結果と思考プロセスの出力を返す
+func runFakeStream(t *testing.T, streamer *fakeStreamer) (string, string, LLMMetadata, error) {
+	t.Helper()
+	var out, thoughtOut strings.Builder
+	metadata, err := streamContent(context.Background(), streamer, LlmRequestConfig{Model: "test-model", InputText: "input"}, &genai.GenerateContentConfig{}, &out, &thoughtOut)
+	return out.String(), thoughtOut.String(), metadata, err
+}
+
+func TestStreamContentNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		apiErr genai.APIError
+	}{
+		{"code and status", genai.APIError{Code: 404, Status: "NOT_FOUND", Message: "models/test-model is not found"}},
+		{"code only", genai.APIError{Code: 404, Message: "models/test-model is not found"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, _, _, err := runFakeStream(t, &fakeStreamer{err: tt.apiErr})
+
+			var notFound *modelNotFoundError
+			if !errors.As(err, &notFound) {
+				t.Fatalf("err = %v, want *modelNotFoundError", err)
+			}
+			if notFound.Model != "test-model" {
+				t.Errorf("Model = %q, want %q", notFound.Model, "test-model")
+			}
+			var gotAPIErr genai.APIError
+			if !errors.As(err, &gotAPIErr) || gotAPIErr.Code != 404 {
+				t.Errorf("err does not wrap the 404 APIError: %v", err)
+			}
+			// モデルの一覧は終了コードがexitModelNotFoundの場合に表示する
+			if got := exitCodeForError(err); got != exitModelNotFound {
+				t.Errorf("exitCodeForError = %d, want %d", got, exitModelNotFound)
+			}
+			if out != "" {
+				t.Errorf("out = %q, want empty", out)
+			}
+		})
+	}
+}
+
+func TestStreamContentOtherAPIErrorIsNotNotFound(t *testing.T) {
+	streamer := &fakeStreamer{err: genai.APIError{Code: 503, Status: "UNAVAILABLE"}}
+	_, _, _, err := runFakeStream(t, streamer)
+
+	var notFound *modelNotFoundError
+	if err == nil || errors.As(err, &notFound) {
+		t.Fatalf("err = %v, want a non-404 error", err)
+	}
+}
+
+func TestStreamContentSeparatesThoughts(t *testing.T) {
+	streamer := &fakeStreamer{responses: []*genai.GenerateContentResponse{
+		candidateResponse("", thoughtPart("考え中...")),
+		candidateResponse("", &genai.Part{Text: "Hello, "}, thoughtPart("もう少し")),
+		candidateResponse(genai.FinishReasonStop, &genai.Part{Text: "world"}),
+	}}
+	out, thoughts, metadata, err := runFakeStream(t, streamer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "Hello, world" {
+		t.Errorf("out = %q, want %q", out, "Hello, world")
+	}
+	if thoughts != "考え中...もう少し" {
+		t.Errorf("thoughts = %q, want %q", thoughts, "考え中...もう少し")
+	}
+	// 文字数と単語数には思考プロセスを含めない
+	if metadata.OutputCharCount != len("Hello, world") || metadata.OutputWordCount != 2 {
+		t.Errorf("OutputCharCount, OutputWordCount = %d, %d, want %d, 2", metadata.OutputCharCount, metadata.OutputWordCount, len("Hello, world"))
+	}
+}
+
+func TestStreamContentAccumulatesMetadata(t *testing.T) {
+	first := candidateResponse("", &genai.Part{Text: "途中"})
+	first.ModelVersion = "test-model-001"
+	first.UsageMetadata = &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 10, TotalTokenCount: 10}
+	last := candidateResponse(genai.FinishReasonStop, &genai.Part{Text: "まで"})
+	last.ModelVersion = "test-model-001"
+	last.UsageMetadata = &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     10,
+		CandidatesTokenCount: 4,
+		ThoughtsTokenCount:   3,
+		TotalTokenCount:      17,
+	}
+	streamer := &fakeStreamer{responses: []*genai.GenerateContentResponse{first, last}}
+	_, _, metadata, err := runFakeStream(t, streamer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// トークン数は最後のチャンクの使用量を使う
+	want := LLMMetadata{
+		Model:                "test-model",
+		ModelVersion:         "test-model-001",
+		FinishReason:         string(genai.FinishReasonStop),
+		PromptTokenCount:     10,
+		CandidatesTokenCount: 4,
+		ThoughtsTokenCount:   3,
+		TotalTokenCount:      17,
+		OutputCharCount:      4,
+		OutputWordCount:      1,
+	}
+	// 時間は実行ごとに変わるため比較しない
+	metadata.APICallTime, metadata.TimeToFirstToken = 0, 0
+	if !reflect.DeepEqual(metadata, want) {
+		t.Errorf("metadata = %+v, want %+v", metadata, want)
+	}
+}
+
+func TestCodeAndOperatorsRoundTripUnescaped(t *testing.T) {
+	inputs := []string{
+		"a < b && c > d",
+		"`if a < b && c > d {}` の意味を教えて",
+		"次のコードを説明して\n```go\nif x < 1 && y > 2 {\n\tfmt.Println(\"<ok> & 'done'\")\n}\n```",
+	}
+	tasks := []string{"translate", "explain"}
+	for _, taskName := range tasks {
+		task, ok := getTaskDefinition(taskName)
+		if !ok {
+			t.Fatalf("task %q not found", taskName)
+		}
+		for _, input := range inputs {
+			llmReqConfig, genaiConfig, err := createLLMConfigs(task, input, nil, requestOptions{ModelName: "gemini-2.5-flash"})
+			if err != nil {
+				t.Fatalf("%s: createLLMConfigs: %v", taskName, err)
+			}
+
+			// 入力はエスケープもタグ付けもせず、プレフィックスとサフィックスで区切るだけ
+			wantInput := task.InputPrefix + input + task.InputSuffix
+			if llmReqConfig.InputText != wantInput {
+				t.Errorf("%s: InputText = %q, want %q", taskName, llmReqConfig.InputText, wantInput)
+			}
+			for _, unwanted := range []string{"&lt;", "&gt;", "&amp;", "&#39;", "&#34;", "<text_to_translate>"} {
+				if strings.Contains(llmReqConfig.InputText, unwanted) {
+					t.Errorf("%s: InputText contains %q: %q", taskName, unwanted, llmReqConfig.InputText)
+				}
+			}
+
+			// モデルの出力は分割されたチャンクのままでも変換せずに書き込む
+			half := len(input) / 2
+			streamer := &fakeStreamer{responses: []*genai.GenerateContentResponse{
+				candidateResponse("", &genai.Part{Text: input[:half]}),
+				candidateResponse(genai.FinishReasonStop, &genai.Part{Text: input[half:]}),
+			}}
+			var out strings.Builder
+			if _, err := streamContent(context.Background(), streamer, llmReqConfig, genaiConfig, &out, io.Discard); err != nil {
+				t.Fatalf("%s: streamContent: %v", taskName, err)
+			}
+			if got := streamer.contents[len(streamer.contents)-1].Parts[0].Text; got != wantInput {
+				t.Errorf("%s: sent text = %q, want %q", taskName, got, wantInput)
+			}
+			if out.String() != input {
+				t.Errorf("%s: output = %q, want %q", taskName, out.String(), input)
+			}
+		}
+	}
+}
+
+func TestStreamContentBlockedOrTruncated(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []*genai.GenerateContentResponse
+		wantErr   any // errors.Asで確認するエラーの型 (nilの場合は型を確認しない)
+		wantExit  int
+	}{
+		{
+			name:      "safety",
+			responses: []*genai.GenerateContentResponse{candidateResponse(genai.FinishReasonSafety)},
+			wantErr:   new(*contentBlockedError),
+			wantExit:  exitBlocked,
+		},
+		{
+			name: "blocked prompt",
+			responses: []*genai.GenerateContentResponse{{
+				PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonSafety},
+			}},
+			wantErr:  new(*contentBlockedError),
+			wantExit: exitBlocked,
+		},
+		{
+			name: "max tokens",
+			responses: []*genai.GenerateContentResponse{
+				candidateResponse("", &genai.Part{Text: "途中まで"}),
+				candidateResponse(genai.FinishReasonMaxTokens, &genai.Part{Text: "の出力"}),
+			},
+			wantErr:  new(*incompleteOutputError),
+			wantExit: exitIncomplete,
+		},
+		{
+			name:      "max tokens without output",
+			responses: []*genai.GenerateContentResponse{candidateResponse(genai.FinishReasonMaxTokens, thoughtPart("考えているうちに上限に達した"))},
+			wantExit:  exitGeneral,
+		},
+		{
+			name:      "empty candidates",
+			responses: []*genai.GenerateContentResponse{{Candidates: []*genai.Candidate{}}},
+			wantExit:  exitGeneral,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := runFakeStream(t, &fakeStreamer{responses: tt.responses})
+			if err == nil {
+				t.Fatal("err = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.As(err, tt.wantErr) {
+				t.Errorf("err = %v (%T), want %T", err, err, tt.wantErr)
+			}
+			if got := exitCodeForError(err); got != tt.wantExit {
+				t.Errorf("exitCodeForError = %d, want %d", got, tt.wantExit)
+			}
+		})
+	}
+}
+
+func TestStreamContentErrorAfterOutputIsIncomplete(t *testing.T) {
+	streamer := &fakeStreamer{
+		responses: []*genai.GenerateContentResponse{candidateResponse("", &genai.Part{Text: "途中まで"})},
+		err:       genai.APIError{Code: 500, Status: "INTERNAL"},
+	}
+	out, _, _, err := runFakeStream(t, streamer)
+	var incomplete *incompleteOutputError
+	if !errors.As(err, &incomplete) {
+		t.Fatalf("err = %v, want *incompleteOutputError", err)
+	}
+	if got := exitCodeForError(err); got != exitIncomplete {
+		t.Errorf("exitCodeForError = %d, want %d", got, exitIncomplete)
+	}
+	if out != "途中まで" {
+		t.Errorf("out = %q, want %q", out, "途中まで")
+	}
+}
+
+func TestMaxTokensCapLeavesRoomForThinking(t *testing.T) {
+	task := TaskDefinition{Name: "test", MaxTokensMultiplier: 4, MaxTokensBase: 100, MaxTokensCap: 1000}
+	budget := int32(4096)
+	reqOpts := requestOptions{ModelName: "gemini-2.5-flash", Thinking: true, ThinkingSet: true, ThinkingBudget: &budget}
+	llmReqConfig, _, err := createLLMConfigs(task, strings.Repeat("a", 10000), nil, reqOpts)
+	if err != nil {
+		t.Fatalf("createLLMConfigs: %v", err)
+	}
+	// 上限は出力の分に適用し、思考予算はその後に加える
+	if want := task.MaxTokensCap + budget; llmReqConfig.MaxTokens != want {
+		t.Errorf("MaxTokens = %d, want %d", llmReqConfig.MaxTokens, want)
+	}
+
+	// -max-tokens の指定は上限より優先する
+	reqOpts.MaxTokens = 500
+	llmReqConfig, _, err = createLLMConfigs(task, strings.Repeat("a", 10000), nil, reqOpts)
+	if err != nil {
+		t.Fatalf("createLLMConfigs: %v", err)
+	}
+	if llmReqConfig.MaxTokens != 500 {
+		t.Errorf("MaxTokens = %d, want 500", llmReqConfig.MaxTokens)
+	}
+}
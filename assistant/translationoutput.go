@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// translateタスクの出力のラベル (行頭の "CONTEXT:"、"ENGLISH:") に一致する正規表現
+// 大文字小文字やラベル前後の空白の違いは許容する
+var (
+	contextLabelPattern = regexp.MustCompile(`(?mi)^[ \t]*CONTEXT[ \t]*:[ \t]*`)
+	englishLabelPattern = regexp.MustCompile(`(?mi)^[ \t]*ENGLISH[ \t]*:[ \t]*`)
+)
+
+// translateタスクの出力をCONTEXTとENGLISHのセクションに分けたもの
+type translationOutput struct {
+	Context string `json:"context,omitempty"`
+	English string `json:"english"`
+	// ENGLISHのラベルが見つかり、セクションに分けられたか
+	// falseの場合、Englishには出力全体が入る
+	Parsed bool `json:"-"`
+}
+
+// translateタスクの出力 (CONTEXT:\n\n...\n\nENGLISH:\n\n...) をセクションに分ける
+// ENGLISHのラベルがない場合は出力全体を翻訳結果として扱う
+func parseTranslationOutput(text string) translationOutput {
+	// CONTEXTのラベルがあれば、その後ろにあるENGLISHのラベルを探す
+	// (翻訳結果の中に同じ文字列が含まれていても最初のラベルで分ける)
+	body := text
+	contextStart := -1
+	if loc := contextLabelPattern.FindStringIndex(text); loc != nil {
+		body = text[loc[1]:]
+		contextStart = loc[1]
+	}
+	loc := englishLabelPattern.FindStringIndex(body)
+	if loc == nil {
+		return translationOutput{English: trimBlankLines(text)}
+	}
+
+	var context string
+	if contextStart >= 0 {
+		context = body[:loc[0]]
+	} else {
+		context = text[:loc[0]]
+	}
+	return translationOutput{
+		Context: strings.TrimSpace(context),
+		English: trimBlankLines(body[loc[1]:]),
+		Parsed:  true,
+	}
+}
+
+// 先頭と末尾の空行 (空白のみの行を含む) と末尾の空白を取り除く
+// Markdownのインデントを保つため、最初の行の行頭の空白は残す
+func trimBlankLines(text string) string {
+	text = strings.TrimRight(text, " \t\r\n")
+	for {
+		line, rest, found := strings.Cut(text, "\n")
+		if !found || strings.TrimSpace(line) != "" {
+			return text
+		}
+		text = rest
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// 書き込まれたテキストを一定の文字数ずつ遅延を挟んで出力するio.Writer
+// 遅延が0の場合は書き込まれたテキストを分割せずにそのまま出力する
+// 書き込みはキューに積まれ、別のgoroutineで出力されるため呼び出し側をブロックしない
+// 出力先がバッファリングする場合 (Flushを持つ場合) も、各ステップごとにフラッシュして即座に表示する
+type typewriter struct {
+	out                  io.Writer
+	chunkSize            int
+	delay                time.Duration
+	queue                chan []byte
+	done                 chan struct{}
+	wrote                bool // 1バイト以上出力したか
+	lastEndedWithNewline bool
+	// trueの場合はCloseで改行を補わず、書き込まれたテキストをそのまま出力する
+	// Closeより前 (最初のWriteより前) に設定すること
+	verbatim bool
+	// 出力先への書き込みに失敗した場合に一度だけ呼び出される (最初のWriteより前に設定すること)
+	// 失敗後は残りのテキストを出力せずに読み捨てる
+	onWriteError func(error)
+	writeErr     error
+}
+
+// outに出力するtypewriterを作成し、出力用のgoroutineを開始する
+// 使い終わったら必ずCloseを呼び出すこと
+func newTypewriter(out io.Writer, chunkSize int, delay time.Duration) *typewriter {
+	t := &typewriter{
+		out:       out,
+		chunkSize: chunkSize,
+		delay:     delay,
+		queue:     make(chan []byte, 100),
+		done:      make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// テキストを出力キューに積む
+func (t *typewriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	t.queue <- buf
+	return len(p), nil
+}
+
+// キューに積まれたテキストをすべて出力し、最後のテキストが改行で終わっていなければ改行を出力する
+// 何も出力していない場合やverbatimの場合は改行を補わない
+func (t *typewriter) Close() error {
+	close(t.queue)
+	<-t.done
+	if t.writeErr != nil {
+		return t.writeErr
+	}
+	if t.wrote && !t.lastEndedWithNewline && !t.verbatim {
+		if _, err := io.WriteString(t.out, "\n"); err != nil {
+			return err
+		}
+		t.flush()
+	}
+	return nil
+}
+
+// 出力先がバッファリングする場合はフラッシュする
+// os.Stdoutのようにバッファリングしない出力先では何もしない
+func (t *typewriter) flush() {
+	if f, ok := t.out.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+}
+
+// 出力先に書き込み、失敗した場合はエラーを記録してfalseを返す
+func (t *typewriter) write(p []byte) bool {
+	if _, err := t.out.Write(p); err != nil {
+		t.writeErr = err
+		if t.onWriteError != nil {
+			t.onWriteError(err)
+		}
+		return false
+	}
+	t.flush()
+	return true
+}
+
+func (t *typewriter) run() {
+	defer close(t.done)
+
+	for text := range t.queue {
+		// 書き込みに失敗した後は読み捨てる
+		if t.writeErr != nil {
+			continue
+		}
+
+		// 遅延がない場合は分割せず、届いたテキストをそのまま出力する
+		if t.delay <= 0 {
+			if !t.write(text) {
+				continue
+			}
+			t.wrote = true
+			t.lastEndedWithNewline = text[len(text)-1] == '\n'
+			continue
+		}
+
+		var start = 0
+		for start < len(text) {
+			end := min(start+t.chunkSize, len(text))
+			if !t.write(text[start:end]) {
+				break
+			}
+			start = end
+			// 最後のチャンクでなければ待機
+			if start < len(text) {
+				time.Sleep(t.delay)
+			}
+		}
+
+		// 最後のテキストが改行かどうかを記録
+		// Writeは空のテキストをキューに積まないため、textは常に1バイト以上
+		t.wrote = true
+		t.lastEndedWithNewline = text[len(text)-1] == '\n'
+	}
+}
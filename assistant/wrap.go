@@ -0,0 +1,199 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// -wrap で端末の幅に合わせる場合に指定する値
+const wrapAuto = "auto"
+
+// -wrap の値を解析し、折り返す表示幅を返す (空の場合は0を返し、折り返さない)
+func parseWrapWidth(value string) (int, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case wrapAuto:
+		return terminalWidth(), nil
+	}
+	width, err := strconv.Atoi(value)
+	if err != nil || width < 1 {
+		return 0, errorf("err.invalidWrap", wrapAuto, value)
+	}
+	return width, nil
+}
+
+// 行の種類
+const (
+	lineUndecided = iota // 行頭を読み込み中で、折り返すかどうかが決まっていない
+	lineWrap             // 折り返す行
+	lineVerbatim         // コードブロックなど、そのまま出力する行
+)
+
+// 書き込まれたテキストを表示幅widthで単語単位に折り返して出力するio.Writer
+// ストリーミング中も単語が揃った時点で出力し、全角文字は1文字ごとに折り返せるものとして扱う
+// Markdownのコードブロック (``` または ~~~ で囲まれた範囲)、4桁以上のインデントやタブで始まる行、表の行 (| で始まる行) は折り返さない
+// 最後にFlushを呼び出して、溜めている単語を出力すること
+type wrapWriter struct {
+	out      io.Writer
+	width    int
+	column   int             // 現在の行の出力済みの表示幅
+	mode     int             // 現在の行の種類
+	head     strings.Builder // 行の種類が決まるまで溜めている行頭のテキスト
+	inFence  bool            // コードブロックの中か
+	word     strings.Builder // 折り返す行で出力を保留している単語
+	wordSize int             // 保留している単語の表示幅
+	spaces   int             // 単語の前に出力を保留している空白の数 (折り返した場合は出力しない)
+	partial  []byte          // 前回の書き込みの末尾にあった不完全なUTF-8の文字
+	buf      strings.Builder // 1回の書き込みで出力するテキスト
+}
+
+// outに折り返したテキストを出力するwrapWriterを作成する
+func newWrapWriter(out io.Writer, width int) *wrapWriter {
+	return &wrapWriter{out: out, width: width}
+}
+
+func (w *wrapWriter) Write(p []byte) (int, error) {
+	data := append(w.partial, p...)
+	w.partial = nil
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			w.partial = data
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+		w.writeRune(r)
+	}
+	return len(p), w.emit()
+}
+
+// 保留しているテキストをすべて出力する
+func (w *wrapWriter) Flush() error {
+	if w.mode == lineUndecided {
+		w.buf.WriteString(w.head.String())
+		w.column += displayWidth(w.head.String())
+		w.head.Reset()
+	}
+	w.flushWord()
+	w.buf.Write(w.partial)
+	w.partial = nil
+	return w.emit()
+}
+
+// 1回の書き込みで溜めたテキストを出力先に書き込む
+func (w *wrapWriter) emit() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.out, w.buf.String())
+	w.buf.Reset()
+	return err
+}
+
+func (w *wrapWriter) writeRune(r rune) {
+	switch w.mode {
+	case lineUndecided:
+		w.readLineHead(r)
+	case lineVerbatim:
+		w.buf.WriteRune(r)
+		if r == '\n' {
+			w.mode, w.column = lineUndecided, 0
+		}
+	case lineWrap:
+		w.wrapRune(r)
+	}
+}
+
+// 行頭の文字を溜め、行の種類が決まったら溜めた文字をその種類に従って出力する
+func (w *wrapWriter) readLineHead(r rune) {
+	if r == '\n' {
+		w.buf.WriteString(w.head.String())
+		w.buf.WriteRune(r)
+		w.head.Reset()
+		w.column = 0
+		return
+	}
+	w.head.WriteRune(r)
+	head := w.head.String()
+	trimmed := strings.TrimLeft(head, " ")
+	switch {
+	case strings.HasPrefix(head, "    ") || strings.HasPrefix(head, "\t") || strings.HasPrefix(trimmed, "\t"):
+		w.mode = lineVerbatim
+	case trimmed == "":
+		// 行頭の空白のみでは決まらない
+		return
+	case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+		w.inFence = !w.inFence
+		w.mode = lineVerbatim
+	case strings.HasPrefix("```", trimmed) || strings.HasPrefix("~~~", trimmed):
+		// コードブロックの区切りの途中の可能性がある
+		return
+	case w.inFence || strings.HasPrefix(trimmed, "|"):
+		w.mode = lineVerbatim
+	default:
+		w.mode = lineWrap
+	}
+
+	w.head.Reset()
+	if w.mode == lineVerbatim {
+		w.buf.WriteString(head)
+		return
+	}
+	for _, r := range head {
+		w.wrapRune(r)
+	}
+}
+
+// 折り返す行の文字を単語に溜め、区切りで単語を出力する
+func (w *wrapWriter) wrapRune(r rune) {
+	switch {
+	case r == '\n':
+		w.flushWord()
+		w.spaces = 0
+		w.buf.WriteRune(r)
+		w.mode, w.column = lineUndecided, 0
+	case r == ' ' || r == '\t':
+		w.flushWord()
+		w.spaces++
+	case runeWidth(r) == 2:
+		// 全角文字は単語の区切りがなくても前後で折り返せる
+		w.flushWord()
+		w.word.WriteRune(r)
+		w.wordSize = 2
+		w.flushWord()
+	default:
+		w.word.WriteRune(r)
+		w.wordSize++
+	}
+}
+
+// 保留している単語を出力する
+// 行に収まらない場合は改行してから出力し (前の空白は出力しない)、幅より長い単語は途中で折り返す
+func (w *wrapWriter) flushWord() {
+	if w.word.Len() == 0 {
+		return
+	}
+	if w.column > 0 && w.column+w.spaces+w.wordSize > w.width {
+		w.buf.WriteByte('\n')
+		w.column = 0
+	} else {
+		w.buf.WriteString(strings.Repeat(" ", w.spaces))
+		w.column += w.spaces
+	}
+	w.spaces = 0
+
+	for _, r := range w.word.String() {
+		rw := runeWidth(r)
+		if w.column > 0 && w.column+rw > w.width {
+			w.buf.WriteByte('\n')
+			w.column = 0
+		}
+		w.buf.WriteRune(r)
+		w.column += rw
+	}
+	w.word.Reset()
+	w.wordSize = 0
+}
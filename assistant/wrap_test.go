@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// chunksを順に書き込んでFlushし、折り返した結果を返す
+func wrapChunks(t *testing.T, width int, chunks ...string) string {
+	t.Helper()
+	var out strings.Builder
+	w := newWrapWriter(&out, width)
+	for _, chunk := range chunks {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	return out.String()
+}
+
+func TestWrapWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		width int
+		input string
+		want  string
+	}{
+		{"words", 10, "hello world foo", "hello\nworld foo"},
+		{"wide runes", 6, "日本語のテキスト", "日本語\nのテキ\nスト"},
+		{"mixed wide and narrow", 6, "abc 日本語", "abc 日\n本語"},
+		{"word longer than width", 5, "ab abcdefghij", "ab\nabcde\nfghij"},
+		{"wide rune at the edge", 5, "abcd日", "abcd\n日"},
+		{"fenced code", 5, "```\nlong code line\n```\nab cd ef", "```\nlong code line\n```\nab cd\nef"},
+		{"indented code", 5, "    long code line\nab cd ef", "    long code line\nab cd\nef"},
+		{"table", 5, "| a | b | c |\n", "| a | b | c |\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapChunks(t, tt.width, tt.input); got != tt.want {
+				t.Errorf("wrap(%q, %d) = %q, want %q", tt.input, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapWriterSplitChunks(t *testing.T) {
+	input := "こんにちは world、折り返しのテスト\n```\nコード ブロック\n```\n"
+	want := wrapChunks(t, 8, input)
+
+	// どのバイト位置で分割しても (UTF-8の文字の途中を含む)、まとめて書き込んだ場合と同じ結果になる
+	for i := 1; i < len(input); i++ {
+		if got := wrapChunks(t, 8, input[:i], input[i:]); got != want {
+			t.Errorf("split at %d: got %q, want %q", i, got, want)
+		}
+	}
+
+	// 1バイトずつ書き込んだ場合も同じ
+	var chunks []string
+	for i := 0; i < len(input); i++ {
+		chunks = append(chunks, input[i:i+1])
+	}
+	if got := wrapChunks(t, 8, chunks...); got != want {
+		t.Errorf("byte by byte: got %q, want %q", got, want)
+	}
+}
+
+func TestParseWrapWidthAuto(t *testing.T) {
+	if isTerminal(os.Stdout) {
+		t.Skip("標準出力が端末の場合は端末の幅が使われる")
+	}
+
+	t.Setenv("COLUMNS", "72")
+	if got, err := parseWrapWidth(wrapAuto); err != nil || got != 72 {
+		t.Errorf("parseWrapWidth(auto) with COLUMNS=72 = %d, %v, want 72", got, err)
+	}
+
+	t.Setenv("COLUMNS", "")
+	if got, err := parseWrapWidth(wrapAuto); err != nil || got != defaultTerminalWidth {
+		t.Errorf("parseWrapWidth(auto) without COLUMNS = %d, %v, want %d", got, err, defaultTerminalWidth)
+	}
+}